@@ -0,0 +1,44 @@
+package minutil
+
+import (
+	"errors"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/gin-gonic/gin"
+)
+
+// ClaimsKey 是 middleware.Auth 在 gin.Context 中保存 *TokenClaims 的键
+const ClaimsKey = "minutil.claims"
+
+// IsTokenExpired 判断 ValidateToken 返回的错误是否为 Token 过期
+func IsTokenExpired(err error) bool {
+	var ve *jwt.ValidationError
+	return errors.As(err, &ve) && ve.Errors&jwt.ValidationErrorExpired != 0
+}
+
+// GetClaims 返回 middleware.Auth 保存的声明
+func GetClaims(c *gin.Context) (*TokenClaims, bool) {
+	val, ok := c.Get(ClaimsKey)
+	if !ok {
+		return nil, false
+	}
+	claims, ok := val.(*TokenClaims)
+	return claims, ok
+}
+
+// MustGetClaims 返回 middleware.Auth 保存的声明，不存在时 panic
+func MustGetClaims(c *gin.Context) *TokenClaims {
+	claims, ok := GetClaims(c)
+	if !ok {
+		panic("minutil: claims not found in context, is middleware.Auth registered?")
+	}
+	return claims
+}
+
+// GetUserID 返回当前请求的用户 ID，未认证时返回空字符串
+func GetUserID(c *gin.Context) string {
+	if claims, ok := GetClaims(c); ok {
+		return claims.UserID
+	}
+	return ""
+}
@@ -0,0 +1,134 @@
+package minutil
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// ClaimOption 在签发 Token 时设置自定义声明
+type ClaimOption func(*TokenClaims)
+
+// WithTenant 设置租户 ID
+func WithTenant(tenantID string) ClaimOption {
+	return func(c *TokenClaims) {
+		c.TenantID = tenantID
+	}
+}
+
+// WithRoles 设置角色列表
+func WithRoles(roles ...string) ClaimOption {
+	return func(c *TokenClaims) {
+		c.Roles = append(c.Roles, roles...)
+	}
+}
+
+// WithScopes 设置授权范围列表
+func WithScopes(scopes ...string) ClaimOption {
+	return func(c *TokenClaims) {
+		c.Scopes = append(c.Scopes, scopes...)
+	}
+}
+
+// WithClaim 在扩展声明中设置一个键值对，value 需要能被 JSON 序列化
+func WithClaim(key string, value interface{}) ClaimOption {
+	return func(c *TokenClaims) {
+		if c.Extra == nil {
+			c.Extra = make(map[string]interface{})
+		}
+		c.Extra[key] = value
+	}
+}
+
+// WithDevice 记录签发 Token 的设备信息，保存在会话中用于展示已登录设备，不会写入 Token
+func WithDevice(userAgent, ip string) ClaimOption {
+	return func(c *TokenClaims) {
+		c.device = deviceInfo{userAgent: userAgent, ip: ip}
+	}
+}
+
+// HasRole 检查是否拥有指定角色
+func (c *TokenClaims) HasRole(role string) bool {
+	return contains(c.Roles, role)
+}
+
+// HasScope 检查是否拥有指定授权范围
+func (c *TokenClaims) HasScope(scope string) bool {
+	return contains(c.Scopes, scope)
+}
+
+// Check 依次执行校验函数，返回第一个错误
+func (c *TokenClaims) Check(validators ...ClaimsValidator) error {
+	for _, validate := range validators {
+		if err := validate(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ClaimValue 从扩展声明中读取 key 并转换为类型 T，
+// 由于 JSON 解码后数字为 float64、对象为 map，这里通过重新编码完成转换
+func ClaimValue[T any](claims *TokenClaims, key string) (T, bool) {
+	var value T
+	raw, ok := claims.Extra[key]
+	if !ok {
+		return value, false
+	}
+	if v, ok := raw.(T); ok {
+		return v, true
+	}
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return value, false
+	}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return value, false
+	}
+	return value, true
+}
+
+// ClaimsValidator 是对已通过签名校验的声明进行业务校验的函数
+type ClaimsValidator func(*TokenClaims) error
+
+// RequireScopes 要求声明包含全部指定的授权范围
+func RequireScopes(scopes ...string) ClaimsValidator {
+	return func(c *TokenClaims) error {
+		for _, scope := range scopes {
+			if !c.HasScope(scope) {
+				return fmt.Errorf("scope %q is required", scope)
+			}
+		}
+		return nil
+	}
+}
+
+// RequireRoles 要求声明至少包含其中一个角色
+func RequireRoles(roles ...string) ClaimsValidator {
+	return func(c *TokenClaims) error {
+		for _, role := range roles {
+			if c.HasRole(role) {
+				return nil
+			}
+		}
+		return fmt.Errorf("one of roles %v is required", roles)
+	}
+}
+
+// RequireTenant 要求声明属于指定租户
+func RequireTenant(tenantID string) ClaimsValidator {
+	return func(c *TokenClaims) error {
+		if c.TenantID != tenantID {
+			return fmt.Errorf("tenant %q is required", tenantID)
+		}
+		return nil
+	}
+}
+
+func contains(values []string, target string) bool {
+	for _, v := range values {
+		if v == target {
+			return true
+		}
+	}
+	return false
+}
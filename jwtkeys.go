@@ -0,0 +1,259 @@
+package minutil
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// SigningMethodEdDSA 是基于 Ed25519 的签名算法，jwt-go v3 未内置该算法
+var SigningMethodEdDSA = &signingMethodEd25519{}
+
+type signingMethodEd25519 struct{}
+
+func init() {
+	jwt.RegisterSigningMethod(SigningMethodEdDSA.Alg(), func() jwt.SigningMethod {
+		return SigningMethodEdDSA
+	})
+}
+
+func (m *signingMethodEd25519) Alg() string {
+	return "EdDSA"
+}
+
+// Sign 使用 ed25519.PrivateKey 对签名串进行签名
+func (m *signingMethodEd25519) Sign(signingString string, key interface{}) (string, error) {
+	privateKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return "", jwt.ErrInvalidKeyType
+	}
+	return jwt.EncodeSegment(ed25519.Sign(privateKey, []byte(signingString))), nil
+}
+
+// Verify 使用 ed25519.PublicKey 校验签名
+func (m *signingMethodEd25519) Verify(signingString, signature string, key interface{}) error {
+	publicKey, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return jwt.ErrInvalidKeyType
+	}
+	sig, err := jwt.DecodeSegment(signature)
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(publicKey, []byte(signingString), sig) {
+		return jwt.ErrSignatureInvalid
+	}
+	return nil
+}
+
+// SigningKey 是密钥环中的一把密钥，通过 kid 标识
+type SigningKey struct {
+	ID        string
+	Method    jwt.SigningMethod
+	SignKey   interface{} // 签名用的私钥，只用于校验的密钥为 nil
+	VerifyKey interface{} // 校验用的公钥（HMAC 时与 SignKey 相同）
+	RetiredAt time.Time   // 退役截止时间，零值表示未退役
+}
+
+// NewHMACKey 创建一把 HS256 密钥
+func NewHMACKey(kid string, secret []byte) *SigningKey {
+	return &SigningKey{
+		ID:        kid,
+		Method:    jwt.SigningMethodHS256,
+		SignKey:   secret,
+		VerifyKey: secret,
+	}
+}
+
+// NewRSAKey 创建一把 RS256 密钥
+func NewRSAKey(kid string, privateKey *rsa.PrivateKey) *SigningKey {
+	return &SigningKey{
+		ID:        kid,
+		Method:    jwt.SigningMethodRS256,
+		SignKey:   privateKey,
+		VerifyKey: &privateKey.PublicKey,
+	}
+}
+
+// NewECDSAKey 创建一把 ECDSA 密钥，根据曲线选择 ES256/ES384/ES512
+func NewECDSAKey(kid string, privateKey *ecdsa.PrivateKey) (*SigningKey, error) {
+	method, err := ecdsaMethod(&privateKey.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	return &SigningKey{
+		ID:        kid,
+		Method:    method,
+		SignKey:   privateKey,
+		VerifyKey: &privateKey.PublicKey,
+	}, nil
+}
+
+// NewEd25519Key 创建一把 EdDSA 密钥
+func NewEd25519Key(kid string, privateKey ed25519.PrivateKey) *SigningKey {
+	return &SigningKey{
+		ID:        kid,
+		Method:    SigningMethodEdDSA,
+		SignKey:   privateKey,
+		VerifyKey: privateKey.Public(),
+	}
+}
+
+// NewVerificationKey 创建一把只用于校验的公钥，适用于只持有公钥的网关
+func NewVerificationKey(kid string, publicKey interface{}) (*SigningKey, error) {
+	var method jwt.SigningMethod
+	switch pub := publicKey.(type) {
+	case *rsa.PublicKey:
+		method = jwt.SigningMethodRS256
+	case *ecdsa.PublicKey:
+		m, err := ecdsaMethod(pub)
+		if err != nil {
+			return nil, err
+		}
+		method = m
+	case ed25519.PublicKey:
+		method = SigningMethodEdDSA
+	default:
+		return nil, fmt.Errorf("unsupported public key type %T", publicKey)
+	}
+	return &SigningKey{
+		ID:        kid,
+		Method:    method,
+		VerifyKey: publicKey,
+	}, nil
+}
+
+func ecdsaMethod(pub *ecdsa.PublicKey) (jwt.SigningMethod, error) {
+	switch pub.Curve {
+	case elliptic.P256():
+		return jwt.SigningMethodES256, nil
+	case elliptic.P384():
+		return jwt.SigningMethodES384, nil
+	case elliptic.P521():
+		return jwt.SigningMethodES512, nil
+	}
+	return nil, errors.New("unsupported ecdsa curve")
+}
+
+// KeyRing 是按 kid 索引的密钥环，新 Token 使用当前激活的密钥签名，
+// 已退役的密钥在宽限期内仍可用于校验
+type KeyRing struct {
+	mu     sync.RWMutex
+	keys   map[string]*SigningKey
+	active string
+}
+
+// NewKeyRing 创建一个密钥环，active 为当前用于签名的密钥，可以为 nil（只校验）
+func NewKeyRing(active *SigningKey, others ...*SigningKey) *KeyRing {
+	kr := &KeyRing{
+		keys: make(map[string]*SigningKey),
+	}
+	for _, key := range others {
+		kr.keys[key.ID] = key
+	}
+	if active != nil {
+		kr.keys[active.ID] = active
+		kr.active = active.ID
+	}
+	return kr
+}
+
+// Add 添加一把密钥，不改变当前激活的密钥
+func (kr *KeyRing) Add(key *SigningKey) {
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+	kr.keys[key.ID] = key
+}
+
+// Rotate 激活一把新密钥，旧的激活密钥在 grace 之后退役
+func (kr *KeyRing) Rotate(next *SigningKey, grace time.Duration) error {
+	if next.SignKey == nil {
+		return errors.New("signing key has no private key")
+	}
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+	if old, ok := kr.keys[kr.active]; ok && old.ID != next.ID {
+		old.RetiredAt = time.Now().Add(grace)
+	}
+	kr.keys[next.ID] = next
+	kr.active = next.ID
+	return nil
+}
+
+// Retire 设置密钥的退役截止时间，之后用它签名的 Token 将无法通过校验
+func (kr *KeyRing) Retire(kid string, until time.Time) error {
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+	key, ok := kr.keys[kid]
+	if !ok {
+		return fmt.Errorf("signing key %q not found", kid)
+	}
+	key.RetiredAt = until
+	return nil
+}
+
+// Remove 从密钥环中移除密钥
+func (kr *KeyRing) Remove(kid string) {
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+	delete(kr.keys, kid)
+	if kr.active == kid {
+		kr.active = ""
+	}
+}
+
+// Active 返回当前用于签名的密钥
+func (kr *KeyRing) Active() (*SigningKey, error) {
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+	key, ok := kr.keys[kr.active]
+	if !ok || key.SignKey == nil {
+		return nil, errors.New("no active signing key")
+	}
+	return key, nil
+}
+
+// PublicKeys 返回所有仍可用于校验的公钥，可以分发给只做校验的服务
+func (kr *KeyRing) PublicKeys() map[string]interface{} {
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+	now := time.Now()
+	keys := make(map[string]interface{}, len(kr.keys))
+	for kid, key := range kr.keys {
+		if _, ok := key.Method.(*jwt.SigningMethodHMAC); ok {
+			continue
+		}
+		if key.RetiredAt.IsZero() || now.Before(key.RetiredAt) {
+			keys[kid] = key.VerifyKey
+		}
+	}
+	return keys
+}
+
+// verifyKey 根据 Token 头部的 kid 和 alg 查找校验密钥，没有 kid 的旧 Token 使用激活密钥
+func (kr *KeyRing) verifyKey(token *jwt.Token, now time.Time) (interface{}, error) {
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		kid = kr.active
+	}
+	key, ok := kr.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	if !key.RetiredAt.IsZero() && !now.Before(key.RetiredAt) {
+		return nil, fmt.Errorf("signing key %q has been retired", kid)
+	}
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method %s", token.Method.Alg())
+	}
+	return key.VerifyKey, nil
+}
@@ -1,6 +1,8 @@
 package minutil
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"sync"
 	"time"
@@ -8,24 +10,134 @@ import (
 	"github.com/dgrijalva/jwt-go"
 )
 
+const (
+	// TokenTypeAccess 是访问 Token 的类型
+	TokenTypeAccess = "access"
+	// TokenTypeRefresh 是刷新 Token 的类型
+	TokenTypeRefresh = "refresh"
+
+	// DefaultAccessTokenTTL 是 Token 对中访问 Token 的默认有效期
+	DefaultAccessTokenTTL = 15 * time.Minute
+	// DefaultRefreshTokenTTL 是 Token 对中刷新 Token 的默认有效期
+	DefaultRefreshTokenTTL = 7 * 24 * time.Hour
+)
+
+var (
+	// ErrTokenInvalidated 表示 Token 已被主动失效
+	ErrTokenInvalidated = errors.New("token is invalidated")
+	// ErrTokenFamilyRevoked 表示 Token 所属的刷新链已被撤销
+	ErrTokenFamilyRevoked = errors.New("token family is revoked")
+	// ErrRefreshTokenReused 表示刷新 Token 被重复使用，整条刷新链已被撤销
+	ErrRefreshTokenReused = errors.New("refresh token reused, token family revoked")
+	// ErrStoreNotConfigured 表示需要 TokenStore 的操作没有配置存储
+	ErrStoreNotConfigured = errors.New("token store is not configured")
+)
+
 // TokenClaims 是 JWT 的声明部分
 type TokenClaims struct {
-	UserID   string `json:"user_id"`
-	Username string `json:"username"`
+	UserID    string `json:"user_id"`
+	Username  string `json:"username"`
+	TokenType string `json:"token_type,omitempty"`
+	Family    string `json:"family,omitempty"` // 会话 ID，同一次登录产生的 Token 共享同一个 Family
+	IssuedMs  int64  `json:"iat_ms,omitempty"` // 毫秒精度的签发时间，用于和用户的失效水位线比较
+
+	TenantID string                 `json:"tenant_id,omitempty"`
+	Roles    []string               `json:"roles,omitempty"`
+	Scopes   []string               `json:"scopes,omitempty"`
+	Extra    map[string]interface{} `json:"extra,omitempty"` // 自定义扩展声明，通过 ClaimValue 读取
 	jwt.StandardClaims
+
+	device deviceInfo // 签发时记录到会话中的设备信息，不写入 Token
+}
+
+// TokenPair 是登录时返回的访问 Token 和刷新 Token
+type TokenPair struct {
+	AccessToken      string    `json:"access_token"`
+	RefreshToken     string    `json:"refresh_token"`
+	ExpiresAt        time.Time `json:"expires_at"`
+	RefreshExpiresAt time.Time `json:"refresh_expires_at"`
 }
 
 // TokenManager 是 Token 管理的接口
 type TokenManager interface {
-	GenerateToken(userID, username string, expiration time.Duration) (string, error)
+	GenerateToken(userID, username string, expiration time.Duration, opts ...ClaimOption) (string, error)
+	GenerateTokenPair(userID, username string, opts ...ClaimOption) (*TokenPair, error)
+	RefreshToken(refreshToken string) (*TokenPair, error)
 	ValidateToken(tokenString string) (*TokenClaims, error)
 	InvalidateToken(tokenString string) error
+	InvalidateUserTokens(userID string) error
+	ListSessions(userID string) ([]*Session, error)
+	RevokeSession(userID, sessionID string) error
 }
 
-// JWTTokenManager 是基于 JWT 的 Token 管理实现
+// JWTTokenManager 是基于 JWT 的 Token 管理实现，
+// 每个实例有独立的密钥、存储、签发者和受众，可以在同一进程中按租户创建多个
 type JWTTokenManager struct {
-	secretKey string
-	store     TokenStore
+	keys       *KeyRing
+	store      TokenStore
+	issuer     string
+	audience   string
+	leeway     time.Duration
+	accessTTL  time.Duration
+	refreshTTL time.Duration
+	validators []ClaimsValidator
+
+	lastSeenInterval time.Duration
+	touchMu          sync.Mutex
+	touched          map[string]time.Time // 会话最近一次写入 LastSeen 的时间
+}
+
+// TokenManagerOption 是 JWTTokenManager 的配置项
+type TokenManagerOption func(*JWTTokenManager)
+
+// WithIssuer 设置签发者，签发时写入 iss，校验时要求 iss 一致
+func WithIssuer(issuer string) TokenManagerOption {
+	return func(tm *JWTTokenManager) {
+		tm.issuer = issuer
+	}
+}
+
+// WithAudience 设置受众，签发时写入 aud，校验时要求 aud 一致
+func WithAudience(audience string) TokenManagerOption {
+	return func(tm *JWTTokenManager) {
+		tm.audience = audience
+	}
+}
+
+// WithLeeway 设置校验 exp/nbf/iat 时允许的时钟偏差
+func WithLeeway(leeway time.Duration) TokenManagerOption {
+	return func(tm *JWTTokenManager) {
+		tm.leeway = leeway
+	}
+}
+
+// WithAccessTTL 设置 Token 对中访问 Token 的有效期
+func WithAccessTTL(ttl time.Duration) TokenManagerOption {
+	return func(tm *JWTTokenManager) {
+		tm.accessTTL = ttl
+	}
+}
+
+// WithRefreshTTL 设置 Token 对中刷新 Token 的有效期
+func WithRefreshTTL(ttl time.Duration) TokenManagerOption {
+	return func(tm *JWTTokenManager) {
+		tm.refreshTTL = ttl
+	}
+}
+
+// WithLastSeenInterval 设置会话最近访问时间的更新间隔，默认为 DefaultLastSeenInterval。
+// 每个实例对同一会话在间隔内最多写一次存储，为 0 时每次校验都写入，小于 0 时不记录最近访问时间
+func WithLastSeenInterval(interval time.Duration) TokenManagerOption {
+	return func(tm *JWTTokenManager) {
+		tm.lastSeenInterval = interval
+	}
+}
+
+// WithValidators 添加在每次 ValidateToken 时执行的声明校验
+func WithValidators(validators ...ClaimsValidator) TokenManagerOption {
+	return func(tm *JWTTokenManager) {
+		tm.validators = append(tm.validators, validators...)
+	}
 }
 
 var (
@@ -33,59 +145,365 @@ var (
 	once     sync.Once
 )
 
-// GetTokenManager 返回单例模式的 TokenManager
+// GetTokenManager 返回单例模式的 TokenManager，使用 HS256 和共享密钥签名。
+// 只有第一次调用的参数生效，之后的调用都返回同一个实例。
+//
+// Deprecated: 使用 NewJWTTokenManager 为每个租户或受众创建独立的实例
 func GetTokenManager(secretKey string, store TokenStore) *JWTTokenManager {
 	once.Do(func() {
-		instance = &JWTTokenManager{
-			secretKey: secretKey,
-			store:     store,
-		}
+		instance = NewJWTTokenManager(NewKeyRing(NewHMACKey("", []byte(secretKey))), store)
 	})
 	return instance
 }
 
-// GenerateToken 生成一个新的 JWT Token
-func (tm *JWTTokenManager) GenerateToken(userID, username string, expiration time.Duration) (string, error) {
+// NewJWTTokenManager 使用密钥环创建 TokenManager，store 为 nil 时不检查失效状态
+func NewJWTTokenManager(keys *KeyRing, store TokenStore, opts ...TokenManagerOption) *JWTTokenManager {
+	tm := &JWTTokenManager{
+		keys:       keys,
+		store:      store,
+		accessTTL:  DefaultAccessTokenTTL,
+		refreshTTL: DefaultRefreshTokenTTL,
+
+		lastSeenInterval: DefaultLastSeenInterval,
+	}
+	for _, opt := range opts {
+		opt(tm)
+	}
+	return tm
+}
+
+// KeyRing 返回签名使用的密钥环，可用于轮换密钥
+func (tm *JWTTokenManager) KeyRing() *KeyRing {
+	return tm.keys
+}
+
+// GenerateToken 生成一个新的 JWT Token，opts 用于设置角色、租户等自定义声明
+func (tm *JWTTokenManager) GenerateToken(userID, username string, expiration time.Duration, opts ...ClaimOption) (string, error) {
+	now := time.Now()
+	claims := TokenClaims{
+		UserID:   userID,
+		Username: username,
+	}
+	for _, opt := range opts {
+		opt(&claims)
+	}
+	// 单独签发的 Token 自成一个会话
+	claims.Family = newTokenID()
+	tm.stamp(&claims, now, now.Add(expiration))
+	token, err := tm.sign(claims)
+	if err != nil {
+		return "", err
+	}
+	if err := tm.recordSession(&claims, now.Add(expiration), nil); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// GenerateTokenPair 登录时生成访问 Token 和刷新 Token，二者属于一条新的刷新链
+func (tm *JWTTokenManager) GenerateTokenPair(userID, username string, opts ...ClaimOption) (*TokenPair, error) {
 	claims := TokenClaims{
 		UserID:   userID,
 		Username: username,
-		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: time.Now().Add(expiration).Unix(),
-			IssuedAt:  time.Now().Unix(),
-		},
+	}
+	for _, opt := range opts {
+		opt(&claims)
+	}
+	claims.Family = newTokenID()
+	return tm.issuePair(claims, nil)
+}
+
+// RefreshToken 使用刷新 Token 换取新的 Token 对，旧的刷新 Token 随即作废。
+// 如果已经用过的刷新 Token 再次出现，整条刷新链都会被撤销
+func (tm *JWTTokenManager) RefreshToken(refreshToken string) (*TokenPair, error) {
+	if tm.store == nil {
+		return nil, ErrStoreNotConfigured
+	}
+	claims, err := tm.parse(refreshToken)
+	if err != nil {
+		return nil, err
+	}
+	if err := tm.checkInvalidated(claims, refreshToken); err != nil {
+		return nil, err
+	}
+	if claims.TokenType != TokenTypeRefresh || claims.Family == "" || claims.Id == "" {
+		return nil, errors.New("not a refresh token")
+	}
+	if err := tm.checkFamily(claims.Family); err != nil {
+		return nil, err
+	}
+	if err := tm.checkUser(claims); err != nil {
+		return nil, err
+	}
+
+	reused, err := tm.store.UseRefreshToken(claims.Id)
+	if err != nil {
+		return nil, err
+	}
+	if reused {
+		if err := tm.store.RevokeFamily(claims.Family, tm.refreshTTL); err != nil {
+			return nil, err
+		}
+		if err := tm.store.DeleteSession(claims.Family); err != nil {
+			return nil, err
+		}
+		return nil, ErrRefreshTokenReused
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(tm.secretKey))
+	// 新的 Token 对沿用刷新 Token 中的用户信息和自定义声明，会话保留原来的设备信息
+	session, err := tm.store.GetSession(claims.Family)
+	if err != nil {
+		return nil, err
+	}
+	base := *claims
+	base.StandardClaims = jwt.StandardClaims{}
+	return tm.issuePair(base, session)
 }
 
 // ValidateToken 验证 JWT Token
 func (tm *JWTTokenManager) ValidateToken(tokenString string) (*TokenClaims, error) {
-	invalid, err := tm.store.Get(tokenString)
+	claims, err := tm.parse(tokenString)
 	if err != nil {
 		return nil, err
 	}
+	if err := tm.checkInvalidated(claims, tokenString); err != nil {
+		return nil, err
+	}
+	if claims.TokenType == TokenTypeRefresh {
+		return nil, errors.New("refresh token can not be used as access token")
+	}
+	if claims.Family != "" {
+		if err := tm.checkFamily(claims.Family); err != nil {
+			return nil, err
+		}
+	}
+	if err := tm.checkUser(claims); err != nil {
+		return nil, err
+	}
+	if err := claims.Check(tm.validators...); err != nil {
+		return nil, err
+	}
+	if err := tm.touchSession(claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
 
-	if invalid {
-		return nil, errors.New("token is invalidated")
+// InvalidateToken 使 Token 失效，失效记录按 jti 保存，只保留到 Token 本身过期为止，没有 exp 的 Token 永久保存
+func (tm *JWTTokenManager) InvalidateToken(tokenString string) error {
+	if tm.store == nil {
+		return ErrStoreNotConfigured
+	}
+	// 必须校验签名，否则伪造的 Token 可以借用他人的 jti 使其失效
+	claims, err := tm.parse(tokenString)
+	if IsTokenExpired(err) {
+		// Token 已经过期，无需记录
+		return nil
+	} else if err != nil {
+		return err
+	}
+	// 没有 exp 的 Token 永不过期，失效记录也需要永久保存
+	var expiration time.Duration
+	if claims.ExpiresAt != 0 {
+		expiration = time.Until(time.Unix(claims.ExpiresAt, 0)) + tm.leeway
+	}
+	return tm.store.Set(revocationKey(claims, tokenString), true, expiration)
+}
+
+// InvalidateUserTokens 使用户在此之前签发的所有 Token 失效，用于修改密码或封禁用户
+func (tm *JWTTokenManager) InvalidateUserTokens(userID string) error {
+	if tm.store == nil {
+		return ErrStoreNotConfigured
+	}
+	if err := tm.store.SetUserNotBefore(userID, time.Now()); err != nil {
+		return err
+	}
+	sessions, err := tm.store.ListSessions(userID)
+	if err != nil {
+		return err
+	}
+	for _, session := range sessions {
+		if err := tm.store.DeleteSession(session.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// issuePair 基于 base 中的用户信息和刷新链签发一对新的 Token，并更新会话记录
+func (tm *JWTTokenManager) issuePair(base TokenClaims, session *Session) (*TokenPair, error) {
+	if tm.store == nil {
+		return nil, ErrStoreNotConfigured
+	}
+	now := time.Now()
+	expiresAt := now.Add(tm.accessTTL)
+	refreshExpiresAt := now.Add(tm.refreshTTL)
+
+	access := base
+	access.TokenType = TokenTypeAccess
+	tm.stamp(&access, now, expiresAt)
+	accessToken, err := tm.sign(access)
+	if err != nil {
+		return nil, err
+	}
+
+	refresh := base
+	refresh.TokenType = TokenTypeRefresh
+	tm.stamp(&refresh, now, refreshExpiresAt)
+	refreshToken, err := tm.sign(refresh)
+	if err != nil {
+		return nil, err
 	}
+	if err := tm.store.SaveRefreshToken(refresh.Id, tm.refreshTTL); err != nil {
+		return nil, err
+	}
+	// 会话的有效期跟随刷新 Token
+	if err := tm.recordSession(&access, refreshExpiresAt, session); err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{
+		AccessToken:      accessToken,
+		RefreshToken:     refreshToken,
+		ExpiresAt:        expiresAt,
+		RefreshExpiresAt: refreshExpiresAt,
+	}, nil
+}
 
-	token, err := jwt.ParseWithClaims(tokenString, &TokenClaims{}, func(token *jwt.Token) (interface{}, error) {
-		return []byte(tm.secretKey), nil
+// sign 使用密钥环中的激活密钥签名
+func (tm *JWTTokenManager) sign(claims TokenClaims) (string, error) {
+	key, err := tm.keys.Active()
+	if err != nil {
+		return "", err
+	}
+	token := jwt.NewWithClaims(key.Method, claims)
+	if key.ID != "" {
+		token.Header["kid"] = key.ID
+	}
+	return token.SignedString(key.SignKey)
+}
+
+// stamp 设置 jti、签发时间、过期时间、签发者和受众
+func (tm *JWTTokenManager) stamp(claims *TokenClaims, issuedAt, expiresAt time.Time) {
+	claims.IssuedMs = issuedAt.UnixMilli()
+	claims.StandardClaims = jwt.StandardClaims{
+		Id:        newTokenID(),
+		Issuer:    tm.issuer,
+		Audience:  tm.audience,
+		ExpiresAt: expiresAt.Unix(),
+		IssuedAt:  issuedAt.Unix(),
+	}
+}
+
+// parse 校验签名和有效期并解析声明
+func (tm *JWTTokenManager) parse(tokenString string) (*TokenClaims, error) {
+	now := time.Now()
+	parser := &jwt.Parser{SkipClaimsValidation: true}
+	token, err := parser.ParseWithClaims(tokenString, &TokenClaims{}, func(token *jwt.Token) (interface{}, error) {
+		return tm.keys.verifyKey(token, now)
 	})
 
 	if err != nil {
 		return nil, err
 	}
 
-	if claims, ok := token.Claims.(*TokenClaims); ok && token.Valid {
-		return claims, nil
+	claims, ok := token.Claims.(*TokenClaims)
+	if !ok || !token.Valid {
+		return nil, errors.New("invalid token")
+	}
+	if err := tm.verifyClaims(claims, now); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// verifyClaims 按照允许的时钟偏差校验时间，并校验签发者和受众
+func (tm *JWTTokenManager) verifyClaims(claims *TokenClaims, now time.Time) error {
+	if !claims.VerifyExpiresAt(now.Add(-tm.leeway).Unix(), false) {
+		return jwt.NewValidationError("token is expired", jwt.ValidationErrorExpired)
+	}
+	if !claims.VerifyNotBefore(now.Add(tm.leeway).Unix(), false) {
+		return jwt.NewValidationError("token is not valid yet", jwt.ValidationErrorNotValidYet)
 	}
+	if !claims.VerifyIssuedAt(now.Add(tm.leeway).Unix(), false) {
+		return jwt.NewValidationError("token used before issued", jwt.ValidationErrorIssuedAt)
+	}
+	if tm.issuer != "" && !claims.VerifyIssuer(tm.issuer, true) {
+		return jwt.NewValidationError("token issuer is invalid", jwt.ValidationErrorIssuer)
+	}
+	if tm.audience != "" && !claims.VerifyAudience(tm.audience, true) {
+		return jwt.NewValidationError("token audience is invalid", jwt.ValidationErrorAudience)
+	}
+	return nil
+}
 
-	return nil, errors.New("invalid token")
+// checkInvalidated 检查 Token 是否已被主动失效
+func (tm *JWTTokenManager) checkInvalidated(claims *TokenClaims, tokenString string) error {
+	if tm.store == nil {
+		return nil
+	}
+	invalid, err := tm.store.Get(revocationKey(claims, tokenString))
+	if err != nil {
+		return err
+	}
+	if invalid {
+		return ErrTokenInvalidated
+	}
+	return nil
 }
 
-// InvalidateToken 使 Token 失效
-func (tm *JWTTokenManager) InvalidateToken(tokenString string) error {
-	return tm.store.Set(tokenString, true)
-}
\ No newline at end of file
+// revocationKey 返回失效记录的键：优先使用 jti，没有 jti 的旧 Token 使用其 SHA-256 摘要，
+// 存储和日志中都不会出现 Token 原文
+func revocationKey(claims *TokenClaims, tokenString string) string {
+	if claims.Id != "" {
+		return claims.Id
+	}
+	return Encrypt(tokenString)
+}
+
+// checkFamily 检查刷新链是否已被撤销
+func (tm *JWTTokenManager) checkFamily(family string) error {
+	if tm.store == nil {
+		return nil
+	}
+	revoked, err := tm.store.IsFamilyRevoked(family)
+	if err != nil {
+		return err
+	}
+	if revoked {
+		return ErrTokenFamilyRevoked
+	}
+	return nil
+}
+
+// checkUser 检查 Token 是否早于用户的失效水位线签发
+func (tm *JWTTokenManager) checkUser(claims *TokenClaims) error {
+	if tm.store == nil || claims.UserID == "" {
+		return nil
+	}
+	notBefore, err := tm.store.GetUserNotBefore(claims.UserID)
+	if err != nil {
+		return err
+	}
+	if notBefore.IsZero() {
+		return nil
+	}
+	// 没有毫秒签发时间的 Token 按秒比较，同一秒内签发的也视为失效
+	issued := claims.IssuedMs
+	if issued == 0 {
+		issued = claims.IssuedAt * 1000
+	}
+	if issued < notBefore.UnixMilli() {
+		return ErrTokenInvalidated
+	}
+	return nil
+}
+
+// newTokenID 生成随机的 Token 标识
+func newTokenID() string {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(buf)
+}
@@ -1,18 +1,27 @@
 package minutil
 
 import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/alicebob/miniredis/v2"
+	"github.com/dgrijalva/jwt-go"
 	"github.com/go-redis/redis/v8"
 )
 
 func TestJWTTokenManager(t *testing.T) {
 	secretKey := "my_secret_key"
 
+	keys := NewKeyRing(NewHMACKey("", []byte(secretKey)))
+
 	// 使用 MapTokenStore 进行测试
 	mapStore := NewMapTokenStore()
-	tokenManager := GetTokenManager(secretKey, mapStore)
+	tokenManager := NewJWTTokenManager(keys, mapStore)
 
 	Warn("Start Map Store Test!")
 	testTokenManager(t, tokenManager)
@@ -21,13 +30,46 @@ func TestJWTTokenManager(t *testing.T) {
 	redisClient := redis.NewClient(&redis.Options{
 		Addr: "localhost:6379",
 	})
+	if err := redisClient.Ping(context.Background()).Err(); err != nil {
+		t.Skipf("Redis is not available: %v", err)
+	}
 	redisStore := NewRedisTokenStore(redisClient)
-	tokenManager = GetTokenManager(secretKey, redisStore)
+	tokenManager = NewJWTTokenManager(keys, redisStore)
 
 	Warn("Start Redis Store Test!")
 	testTokenManager(t, tokenManager)
 }
 
+func TestJWTTokenManagerPerTenant(t *testing.T) {
+	keys := NewKeyRing(NewHMACKey("k1", []byte("my_secret_key")))
+	tenantA := NewJWTTokenManager(keys, NewMapTokenStore(), WithIssuer("auth"), WithAudience("tenant-a"))
+	tenantB := NewJWTTokenManager(keys, NewMapTokenStore(), WithIssuer("auth"), WithAudience("tenant-b"))
+
+	token, err := tenantA.GenerateToken("123456", "testuser", time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+	if _, err := tenantA.ValidateToken(token); err != nil {
+		t.Errorf("Expected token to be valid for its own tenant, got %v", err)
+	}
+	if _, err := tenantB.ValidateToken(token); err == nil {
+		t.Errorf("Expected token to be rejected by another tenant")
+	}
+
+	// 在允许的时钟偏差内，刚过期的 Token 仍然有效
+	lenient := NewJWTTokenManager(keys, NewMapTokenStore(), WithLeeway(time.Minute))
+	expired, err := lenient.GenerateToken("123456", "testuser", -10*time.Second)
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+	if _, err := lenient.ValidateToken(expired); err != nil {
+		t.Errorf("Expected token within leeway to be valid, got %v", err)
+	}
+	if _, err := tenantA.ValidateToken(expired); err == nil {
+		t.Errorf("Expected token to be expired without leeway")
+	}
+}
+
 func testTokenManager(t *testing.T, tokenManager *JWTTokenManager) {
 	userID := "123456"
 	username := "testuser"
@@ -78,3 +120,420 @@ func testTokenManager(t *testing.T, tokenManager *JWTTokenManager) {
 		t.Errorf("Expected token to be expired, but it was valid")
 	}
 }
+
+func TestJWTKeyRotation(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate rsa key: %v", err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate ed25519 key: %v", err)
+	}
+
+	keys := NewKeyRing(NewRSAKey("rsa-1", rsaKey))
+	tokenManager := NewJWTTokenManager(keys, NewMapTokenStore())
+
+	oldToken, err := tokenManager.GenerateToken("123456", "testuser", time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+
+	// 轮换到 EdDSA 密钥，旧密钥在宽限期内仍然有效
+	if err := keys.Rotate(NewEd25519Key("ed-1", edKey), time.Hour); err != nil {
+		t.Fatalf("Failed to rotate key: %v", err)
+	}
+	newToken, err := tokenManager.GenerateToken("123456", "testuser", time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+	if _, err := tokenManager.ValidateToken(oldToken); err != nil {
+		t.Errorf("Expected token signed by retired key to be valid during grace period, got %v", err)
+	}
+
+	// 只持有公钥的网关也可以校验
+	var gatewayKeys []*SigningKey
+	for kid, pub := range keys.PublicKeys() {
+		key, err := NewVerificationKey(kid, pub)
+		if err != nil {
+			t.Fatalf("Failed to create verification key: %v", err)
+		}
+		gatewayKeys = append(gatewayKeys, key)
+	}
+	gateway := NewJWTTokenManager(NewKeyRing(nil, gatewayKeys...), nil)
+	if _, err := gateway.ValidateToken(newToken); err != nil {
+		t.Errorf("Expected gateway to validate token, got %v", err)
+	}
+	if _, err := gateway.GenerateToken("123456", "testuser", time.Hour); err == nil {
+		t.Errorf("Expected gateway without private key to fail signing")
+	}
+
+	// 宽限期结束后旧 Token 失效
+	if err := keys.Retire("rsa-1", time.Now()); err != nil {
+		t.Fatalf("Failed to retire key: %v", err)
+	}
+	if _, err := tokenManager.ValidateToken(oldToken); err == nil {
+		t.Errorf("Expected token signed by retired key to be rejected")
+	}
+}
+
+func TestJWTRefreshTokenRotation(t *testing.T) {
+	tokenManager := NewJWTTokenManager(NewKeyRing(NewHMACKey("k1", []byte("my_secret_key"))), NewMapTokenStore())
+
+	pair, err := tokenManager.GenerateTokenPair("123456", "testuser")
+	if err != nil {
+		t.Fatalf("Failed to generate token pair: %v", err)
+	}
+	if _, err := tokenManager.ValidateToken(pair.AccessToken); err != nil {
+		t.Fatalf("Failed to validate access token: %v", err)
+	}
+	if _, err := tokenManager.ValidateToken(pair.RefreshToken); err == nil {
+		t.Errorf("Expected refresh token to be rejected as access token")
+	}
+
+	next, err := tokenManager.RefreshToken(pair.RefreshToken)
+	if err != nil {
+		t.Fatalf("Failed to refresh token: %v", err)
+	}
+	if next.RefreshToken == pair.RefreshToken {
+		t.Errorf("Expected refresh token to be rotated")
+	}
+
+	// 旧的刷新 Token 再次出现，整条刷新链被撤销
+	if _, err := tokenManager.RefreshToken(pair.RefreshToken); err != ErrRefreshTokenReused {
+		t.Errorf("Expected ErrRefreshTokenReused, got %v", err)
+	}
+	if _, err := tokenManager.RefreshToken(next.RefreshToken); err != ErrTokenFamilyRevoked {
+		t.Errorf("Expected ErrTokenFamilyRevoked, got %v", err)
+	}
+	if _, err := tokenManager.ValidateToken(next.AccessToken); err != ErrTokenFamilyRevoked {
+		t.Errorf("Expected access token of revoked family to be rejected, got %v", err)
+	}
+}
+
+func TestJWTCustomClaims(t *testing.T) {
+	type profile struct {
+		Plan  string `json:"plan"`
+		Seats int    `json:"seats"`
+	}
+	keys := NewKeyRing(NewHMACKey("k1", []byte("my_secret_key")))
+	tokenManager := NewJWTTokenManager(keys, NewMapTokenStore(), WithValidators(RequireTenant("acme")))
+
+	pair, err := tokenManager.GenerateTokenPair("123456", "testuser",
+		WithTenant("acme"),
+		WithRoles("admin"),
+		WithScopes("orders:read", "orders:write"),
+		WithClaim("profile", profile{Plan: "pro", Seats: 5}),
+	)
+	if err != nil {
+		t.Fatalf("Failed to generate token pair: %v", err)
+	}
+
+	// 刷新后自定义声明保持不变
+	pair, err = tokenManager.RefreshToken(pair.RefreshToken)
+	if err != nil {
+		t.Fatalf("Failed to refresh token: %v", err)
+	}
+	claims, err := tokenManager.ValidateToken(pair.AccessToken)
+	if err != nil {
+		t.Fatalf("Failed to validate token: %v", err)
+	}
+	if !claims.HasRole("admin") || claims.TenantID != "acme" {
+		t.Errorf("Expected role and tenant to be kept, got %v %s", claims.Roles, claims.TenantID)
+	}
+	if err := claims.Check(RequireScopes("orders:write")); err != nil {
+		t.Errorf("Expected scope check to pass, got %v", err)
+	}
+	if err := claims.Check(RequireScopes("orders:delete")); err == nil {
+		t.Errorf("Expected scope check to fail")
+	}
+	if p, ok := ClaimValue[profile](claims, "profile"); !ok || p.Seats != 5 {
+		t.Errorf("Expected typed extra claim, got %+v %v", p, ok)
+	}
+
+	other, err := tokenManager.GenerateToken("123456", "testuser", time.Hour, WithTenant("other"))
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+	if _, err := tokenManager.ValidateToken(other); err == nil {
+		t.Errorf("Expected token of another tenant to be rejected")
+	}
+}
+
+func TestMapTokenStoreExpiry(t *testing.T) {
+	store := NewMapTokenStore(WithSweepInterval(10 * time.Millisecond))
+	defer store.Close()
+
+	tokenManager := NewJWTTokenManager(NewKeyRing(NewHMACKey("k1", []byte("my_secret_key"))), store)
+	token, err := tokenManager.GenerateToken("123456", "testuser", time.Second)
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+	if err := tokenManager.InvalidateToken(token); err != nil {
+		t.Fatalf("Failed to invalidate token: %v", err)
+	}
+	if _, err := tokenManager.ValidateToken(token); err != ErrTokenInvalidated {
+		t.Fatalf("Expected token to be invalidated, got %v", err)
+	}
+
+	// Token 过期后失效记录被后台协程清理
+	time.Sleep(1500 * time.Millisecond)
+	store.mu.RLock()
+	n := len(store.store)
+	store.mu.RUnlock()
+	if n != 0 {
+		t.Errorf("Expected expired revocation to be swept, got %d entries", n)
+	}
+}
+
+func TestJWTInvalidateUserTokens(t *testing.T) {
+	tokenManager := NewJWTTokenManager(NewKeyRing(NewHMACKey("k1", []byte("my_secret_key"))), NewMapTokenStore())
+
+	token, err := tokenManager.GenerateToken("123456", "testuser", time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+	pair, err := tokenManager.GenerateTokenPair("123456", "testuser")
+	if err != nil {
+		t.Fatalf("Failed to generate token pair: %v", err)
+	}
+	other, err := tokenManager.GenerateToken("654321", "otheruser", time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+
+	time.Sleep(2 * time.Millisecond)
+	if err := tokenManager.InvalidateUserTokens("123456"); err != nil {
+		t.Fatalf("Failed to invalidate user tokens: %v", err)
+	}
+	time.Sleep(2 * time.Millisecond)
+
+	if _, err := tokenManager.ValidateToken(token); err != ErrTokenInvalidated {
+		t.Errorf("Expected ErrTokenInvalidated, got %v", err)
+	}
+	if _, err := tokenManager.RefreshToken(pair.RefreshToken); err != ErrTokenInvalidated {
+		t.Errorf("Expected refresh token to be invalidated, got %v", err)
+	}
+	if _, err := tokenManager.ValidateToken(other); err != nil {
+		t.Errorf("Expected other user's token to stay valid, got %v", err)
+	}
+
+	// 之后重新登录签发的 Token 不受影响
+	fresh, err := tokenManager.GenerateToken("123456", "testuser", time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+	if _, err := tokenManager.ValidateToken(fresh); err != nil {
+		t.Errorf("Expected token issued after revocation to be valid, got %v", err)
+	}
+}
+
+func TestJWTSessions(t *testing.T) {
+	tokenManager := NewJWTTokenManager(NewKeyRing(NewHMACKey("k1", []byte("my_secret_key"))), NewMapTokenStore())
+
+	laptop, err := tokenManager.GenerateTokenPair("123456", "testuser", WithDevice("Mozilla/5.0 (Macintosh)", "10.0.0.1"))
+	if err != nil {
+		t.Fatalf("Failed to generate token pair: %v", err)
+	}
+	phone, err := tokenManager.GenerateTokenPair("123456", "testuser", WithDevice("Mozilla/5.0 (iPhone)", "10.0.0.2"))
+	if err != nil {
+		t.Fatalf("Failed to generate token pair: %v", err)
+	}
+
+	// 刷新不会产生新的会话，设备信息保持不变
+	laptop, err = tokenManager.RefreshToken(laptop.RefreshToken)
+	if err != nil {
+		t.Fatalf("Failed to refresh token: %v", err)
+	}
+	sessions, err := tokenManager.ListSessions("123456")
+	if err != nil {
+		t.Fatalf("Failed to list sessions: %v", err)
+	}
+	if len(sessions) != 2 {
+		t.Fatalf("Expected 2 sessions, got %d", len(sessions))
+	}
+
+	claims, err := tokenManager.ValidateToken(phone.AccessToken)
+	if err != nil {
+		t.Fatalf("Failed to validate token: %v", err)
+	}
+	if claims.Id == "" {
+		t.Errorf("Expected token to carry a jti")
+	}
+	if err := tokenManager.RevokeSession("654321", claims.Family); err != ErrSessionNotFound {
+		t.Errorf("Expected other user to be unable to revoke session, got %v", err)
+	}
+	if err := tokenManager.RevokeSession("123456", claims.Family); err != nil {
+		t.Fatalf("Failed to revoke session: %v", err)
+	}
+	if _, err := tokenManager.ValidateToken(phone.AccessToken); err != ErrTokenFamilyRevoked {
+		t.Errorf("Expected revoked session to be rejected, got %v", err)
+	}
+	if _, err := tokenManager.ValidateToken(laptop.AccessToken); err != nil {
+		t.Errorf("Expected other session to stay valid, got %v", err)
+	}
+
+	sessions, err = tokenManager.ListSessions("123456")
+	if err != nil {
+		t.Fatalf("Failed to list sessions: %v", err)
+	}
+	if len(sessions) != 1 || sessions[0].UserAgent != "Mozilla/5.0 (Macintosh)" {
+		t.Errorf("Expected only the laptop session to remain, got %+v", sessions)
+	}
+
+	// 已经过期的 Token 不会出现在已登录设备中
+	if _, err := tokenManager.GenerateToken("654321", "testuser", -time.Hour); err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+	if sessions, _ := tokenManager.ListSessions("654321"); len(sessions) != 0 {
+		t.Errorf("Expected no session for an expired token, got %+v", sessions)
+	}
+}
+
+// touchCountingStore 统计 TouchSession 的调用次数
+type touchCountingStore struct {
+	*MapTokenStore
+	touches int
+}
+
+func (s *touchCountingStore) TouchSession(id string, lastSeen time.Time) error {
+	s.touches++
+	return s.MapTokenStore.TouchSession(id, lastSeen)
+}
+
+func TestJWTSessionLastSeen(t *testing.T) {
+	for interval, want := range map[time.Duration]int{time.Minute: 1, 0: 5, -1: 0} {
+		store := &touchCountingStore{MapTokenStore: NewMapTokenStore()}
+		tokenManager := NewJWTTokenManager(NewKeyRing(NewHMACKey("k1", []byte("my_secret_key"))), store, WithLastSeenInterval(interval))
+		token, err := tokenManager.GenerateToken("123456", "testuser", time.Hour)
+		if err != nil {
+			t.Fatalf("Failed to generate token: %v", err)
+		}
+		for i := 0; i < 5; i++ {
+			if _, err := tokenManager.ValidateToken(token); err != nil {
+				t.Fatalf("Failed to validate token: %v", err)
+			}
+		}
+		// 间隔内重复校验不会每次都写存储
+		if store.touches != want {
+			t.Errorf("Expected %d session writes with interval %v, got %d", want, interval, store.touches)
+		}
+		store.Close()
+	}
+}
+
+func TestRedisSessionExpired(t *testing.T) {
+	mr := miniredis.RunT(t)
+	store := NewRedisTokenStore(redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+	tokenManager := NewJWTTokenManager(NewKeyRing(NewHMACKey("k1", []byte("my_secret_key"))), store)
+
+	if _, err := tokenManager.GenerateToken("123456", "testuser", time.Hour); err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+	if _, err := tokenManager.GenerateToken("123456", "testuser", -time.Hour); err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+	sessions, err := tokenManager.ListSessions("123456")
+	if err != nil || len(sessions) != 1 {
+		t.Fatalf("Expected only the valid session, got %+v %v", sessions, err)
+	}
+
+	// 有效期小于等于 0 时删除会话，不留下没有过期时间的 key
+	if err := store.SaveSession(sessions[0], 0); err != nil {
+		t.Fatalf("Failed to save session: %v", err)
+	}
+	for _, key := range mr.Keys() {
+		if mr.TTL(key) <= 0 {
+			t.Errorf("Expected key %q to expire", key)
+		}
+	}
+	if sessions, _ := tokenManager.ListSessions("123456"); len(sessions) != 0 {
+		t.Errorf("Expected session to be deleted, got %+v", sessions)
+	}
+}
+
+func TestRedisTokenStorePrefix(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	keys := NewKeyRing(NewHMACKey("k1", []byte("my_secret_key")))
+
+	for _, store := range []*RedisTokenStore{NewRedisTokenStore(client), NewRedisTokenStore(client, WithRedisPrefix("app2:"))} {
+		tokenManager := NewJWTTokenManager(keys, store)
+		pair, err := tokenManager.GenerateTokenPair("123456", "testuser")
+		if err != nil {
+			t.Fatalf("Failed to generate token pair: %v", err)
+		}
+		if err := tokenManager.InvalidateToken(pair.AccessToken); err != nil {
+			t.Fatalf("Failed to invalidate token: %v", err)
+		}
+	}
+
+	// 全部 key 都带有前缀，两个前缀的记录互不影响
+	var defaults, app2 int
+	for _, key := range mr.Keys() {
+		switch {
+		case strings.HasPrefix(key, DefaultRedisTokenPrefix):
+			defaults++
+		case strings.HasPrefix(key, "app2:"):
+			app2++
+		default:
+			t.Errorf("Expected key %q to be namespaced", key)
+		}
+	}
+	if defaults == 0 || defaults != app2 {
+		t.Errorf("Expected the same records under both prefixes, got %d and %d", defaults, app2)
+	}
+}
+
+func TestInvalidateTokenWithoutExpiry(t *testing.T) {
+	store := NewMapTokenStore(WithSweepInterval(0))
+	tokenManager := NewJWTTokenManager(NewKeyRing(NewHMACKey("k1", []byte("my_secret_key"))), store)
+	token, err := tokenManager.sign(TokenClaims{UserID: "123456", StandardClaims: jwt.StandardClaims{Id: newTokenID()}})
+	if err != nil {
+		t.Fatalf("Failed to sign token: %v", err)
+	}
+	if err := tokenManager.InvalidateToken(token); err != nil {
+		t.Fatalf("Failed to invalidate token: %v", err)
+	}
+
+	// 没有 exp 的 Token 永不过期，失效记录不能在之后被清理
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+	if len(store.store) != 1 {
+		t.Fatalf("Expected one revocation entry, got %d", len(store.store))
+	}
+	for id, entry := range store.store {
+		if !entry.invalid || !entry.expiresAt.IsZero() {
+			t.Errorf("Expected revocation of %q to be kept forever, got %+v", id, entry)
+		}
+	}
+}
+
+func TestTokenStoreKeys(t *testing.T) {
+	store := NewMapTokenStore()
+	defer store.Close()
+	tokenManager := NewJWTTokenManager(NewKeyRing(NewHMACKey("k1", []byte("my_secret_key"))), store)
+
+	token, err := tokenManager.GenerateToken("123456", "testuser", time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+	if err := tokenManager.InvalidateToken(token); err != nil {
+		t.Fatalf("Failed to invalidate token: %v", err)
+	}
+
+	// 失效记录以 jti 为键，存储中不出现 Token 原文
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+	for key := range store.store {
+		if key == token || len(key) != 32 {
+			t.Errorf("Expected revocation to be keyed by jti, got %q", key)
+		}
+	}
+
+	// 伪造签名的 Token 不能使他人的 Token 失效
+	forged := token[:len(token)-4] + "AAAA"
+	if err := tokenManager.InvalidateToken(forged); err == nil {
+		t.Errorf("Expected forged token to be rejected")
+	}
+}
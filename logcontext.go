@@ -0,0 +1,142 @@
+package minutil
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+)
+
+// logContextKey 是日志相关信息在 context 中的键
+type logContextKey int
+
+const (
+	requestIDKey logContextKey = iota
+	traceKey
+	loggerKey
+)
+
+// traceInfo 是分布式追踪的 trace ID 和 span ID
+type traceInfo struct {
+	traceID string
+	spanID  string
+}
+
+// ContextWithRequestID 返回带有请求 ID 的 context
+func ContextWithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey, requestID)
+}
+
+// RequestIDFromContext 返回 context 中的请求 ID，没有时返回空字符串
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := requestContext(ctx).Value(requestIDKey).(string)
+	return id
+}
+
+// ContextWithTrace 返回带有 trace ID 和 span ID 的 context
+func ContextWithTrace(ctx context.Context, traceID, spanID string) context.Context {
+	return context.WithValue(ctx, traceKey, traceInfo{traceID: traceID, spanID: spanID})
+}
+
+// TraceFromContext 返回 context 中的 trace ID 和 span ID
+func TraceFromContext(ctx context.Context) (traceID, spanID string) {
+	trace, _ := requestContext(ctx).Value(traceKey).(traceInfo)
+	return trace.traceID, trace.spanID
+}
+
+// ContextWithLogger 返回带有记录器的 context，例如绑定了用户 ID 的子记录器
+func ContextWithLogger(ctx context.Context, l *Logger) context.Context {
+	return context.WithValue(ctx, loggerKey, l)
+}
+
+// LoggerFromContext 返回 context 中的记录器，没有时返回 GetLogger()
+func LoggerFromContext(ctx context.Context) *Logger {
+	if l, ok := requestContext(ctx).Value(loggerKey).(*Logger); ok {
+		return l
+	}
+	return GetLogger()
+}
+
+// requestContext 在 ctx 是 *gin.Context 时返回其请求的 context，
+// 因为 gin 默认不会从 Request.Context() 中查找非字符串的键
+func requestContext(ctx context.Context) context.Context {
+	if c, ok := ctx.(*gin.Context); ok && c.Request != nil {
+		return c.Request.Context()
+	}
+	if ctx == nil {
+		return context.Background()
+	}
+	return ctx
+}
+
+// contextFields 返回 context 中需要写入日志的字段
+func contextFields(ctx context.Context) []interface{} {
+	var kv []interface{}
+	if id := RequestIDFromContext(ctx); id != "" {
+		kv = append(kv, "request_id", id)
+	}
+	if traceID, spanID := TraceFromContext(ctx); traceID != "" {
+		kv = append(kv, "trace_id", traceID)
+		if spanID != "" {
+			kv = append(kv, "span_id", spanID)
+		}
+	}
+	return kv
+}
+
+// logCtx 记录日志，并附加 context 中的请求 ID 和 trace ID
+func (l *Logger) logCtx(skip int, ctx context.Context, level Level, msg string, kv []interface{}) {
+	if !l.Enabled(level) {
+		return
+	}
+	l.log(skip+1, level, msg, append(contextFields(ctx), kv...))
+}
+
+// DebugCtx 记录调试级别的日志，并附加 context 中的请求 ID 和 trace ID
+func (l *Logger) DebugCtx(ctx context.Context, msg string, kv ...interface{}) {
+	l.logCtx(1, ctx, LevelDebug, msg, kv)
+}
+
+// InfoCtx 记录信息级别的日志，并附加 context 中的请求 ID 和 trace ID
+func (l *Logger) InfoCtx(ctx context.Context, msg string, kv ...interface{}) {
+	l.logCtx(1, ctx, LevelInfo, msg, kv)
+}
+
+// WarnCtx 记录警告级别的日志，并附加 context 中的请求 ID 和 trace ID
+func (l *Logger) WarnCtx(ctx context.Context, msg string, kv ...interface{}) {
+	l.logCtx(1, ctx, LevelWarn, msg, kv)
+}
+
+// ErrorCtx 记录错误级别的日志，并附加 context 中的请求 ID 和 trace ID
+func (l *Logger) ErrorCtx(ctx context.Context, msg string, kv ...interface{}) {
+	l.logCtx(1, ctx, LevelError, msg, kv)
+}
+
+// logCtxf 按格式记录日志，使用 context 中的记录器
+func logCtxf(ctx context.Context, level Level, format string, v []interface{}) {
+	l := LoggerFromContext(ctx)
+	if !l.Enabled(level) {
+		return
+	}
+	l.logCtx(2, ctx, level, fmt.Sprintf(format, v...), nil)
+}
+
+// InfoCtx 记录信息级别的日志，并附加 context 中的请求 ID 和 trace ID
+func InfoCtx(ctx context.Context, format string, v ...interface{}) {
+	logCtxf(ctx, LevelInfo, format, v)
+}
+
+// ErrorCtx 记录错误级别的日志，并附加 context 中的请求 ID 和 trace ID
+func ErrorCtx(ctx context.Context, format string, v ...interface{}) {
+	logCtxf(ctx, LevelError, format, v)
+}
+
+// WarnCtx 记录警告级别的日志，并附加 context 中的请求 ID 和 trace ID
+func WarnCtx(ctx context.Context, format string, v ...interface{}) {
+	logCtxf(ctx, LevelWarn, format, v)
+}
+
+// DebugCtx 记录调试级别的日志，并附加 context 中的请求 ID 和 trace ID
+func DebugCtx(ctx context.Context, format string, v ...interface{}) {
+	logCtxf(ctx, LevelDebug, format, v)
+}
@@ -0,0 +1,121 @@
+package minutil
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Encoder 把一条日志编码为一行输出，返回的内容需要以换行结尾
+type Encoder interface {
+	Encode(entry *Entry) []byte
+}
+
+// ConsoleEncoder 是面向终端的格式，适合开发环境：
+//
+//	[MIN] [2006-01-02 15:04:05] [pkg/file.go:12 -> Func] [INFO] message key=value
+type ConsoleEncoder struct {
+	Color bool // 是否输出 ANSI 颜色
+}
+
+// levelColors 是各级别在终端中的颜色
+var levelColors = map[Level]string{
+	LevelDebug: "\033[36m", // 青色
+	LevelInfo:  "\033[32m", // 绿色
+	LevelWarn:  "\033[33m", // 黄色
+	LevelError: "\033[31m", // 红色
+	LevelFatal: "\033[35m", // 紫色
+}
+
+// Encode 按终端格式编码日志
+func (e *ConsoleEncoder) Encode(entry *Entry) []byte {
+	var b bytes.Buffer
+	now := entry.Time.Format("2006-01-02 15:04:05")
+	caller := fmt.Sprintf("%s/%s:%d -> %s", entry.Caller.Package, entry.Caller.File, entry.Caller.Line, entry.Caller.Function)
+	if e.Color {
+		levelColor, ok := levelColors[entry.Level]
+		if !ok {
+			levelColor = "\033[0m" // 默认颜色
+		}
+		fmt.Fprintf(&b, "[\033[35mMIN\033[0m] [\033[34m%s\033[0m] [\033[36m%s\033[0m] [\033[33m%s%s\033[0m] %s", now, caller, levelColor, entry.Level, entry.Message)
+	} else {
+		fmt.Fprintf(&b, "[MIN] [%s] [%s] [%s] %s", now, caller, entry.Level, entry.Message)
+	}
+	for _, f := range entry.Fields {
+		b.WriteByte(' ')
+		b.WriteString(f.Key)
+		b.WriteByte('=')
+		b.WriteString(consoleValue(f.Value))
+	}
+	b.WriteByte('\n')
+	return b.Bytes()
+}
+
+// consoleValue 把字段值格式化为文本，包含空格或引号的值加上引号
+func consoleValue(v interface{}) string {
+	var s string
+	switch v := v.(type) {
+	case string:
+		s = v
+	case error:
+		s = v.Error()
+	case fmt.Stringer:
+		s = v.String()
+	default:
+		s = fmt.Sprint(v)
+	}
+	if s == "" || bytes.ContainsAny([]byte(s), " \t\n\"=") {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+// JSONEncoder 把日志编码为一行 JSON，适合日志采集：
+//
+//	{"time":"...","level":"INFO","caller":"pkg/file.go:12","func":"Func","msg":"message","key":"value"}
+type JSONEncoder struct{}
+
+// Encode 按 JSON 格式编码日志，字段与内置键重名时仍然按顺序输出
+func (e *JSONEncoder) Encode(entry *Entry) []byte {
+	var b bytes.Buffer
+	b.WriteString(`{"time":`)
+	writeJSON(&b, entry.Time.Format(time.RFC3339Nano))
+	b.WriteString(`,"level":`)
+	writeJSON(&b, entry.Level.String())
+	b.WriteString(`,"caller":`)
+	writeJSON(&b, fmt.Sprintf("%s/%s:%d", entry.Caller.Package, entry.Caller.File, entry.Caller.Line))
+	b.WriteString(`,"func":`)
+	writeJSON(&b, entry.Caller.Function)
+	b.WriteString(`,"msg":`)
+	writeJSON(&b, entry.Message)
+	for _, f := range entry.Fields {
+		b.WriteByte(',')
+		writeJSON(&b, f.Key)
+		b.WriteByte(':')
+		writeJSON(&b, jsonValue(f.Value))
+	}
+	b.WriteString("}\n")
+	return b.Bytes()
+}
+
+// jsonValue 把无法直接序列化的值转换为文本
+func jsonValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case error:
+		return v.Error()
+	case time.Duration:
+		return v.String()
+	}
+	return v
+}
+
+// writeJSON 写入 v 的 JSON 编码，无法编码时写入 fmt.Sprint 的结果
+func writeJSON(b *bytes.Buffer, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		data, _ = json.Marshal(fmt.Sprint(v))
+	}
+	b.Write(data)
+}
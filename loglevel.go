@@ -0,0 +1,277 @@
+package minutil
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// SetLevel 修改全局最低输出级别，对父子记录器同时生效，可以在运行时调用
+func (l *Logger) SetLevel(level Level) {
+	l.core.setLevel(level)
+}
+
+// Level 返回全局最低输出级别
+func (l *Logger) Level() Level {
+	return Level(l.core.level.Load())
+}
+
+// SetPackageLevel 单独设置某个包的最低输出级别，pkg 是包所在的目录名，与日志中的调用位置一致，
+// 例如 SetPackageLevel("middleware", LevelDebug)
+func (l *Logger) SetPackageLevel(pkg string, level Level) {
+	c := l.core
+	c.levelMu.Lock()
+	defer c.levelMu.Unlock()
+	if c.packages == nil {
+		c.packages = make(map[string]Level)
+	}
+	c.packages[pkg] = level
+	c.updateMinLevel()
+}
+
+// ResetPackageLevel 删除包级别，该包恢复使用全局级别
+func (l *Logger) ResetPackageLevel(pkg string) {
+	c := l.core
+	c.levelMu.Lock()
+	defer c.levelMu.Unlock()
+	delete(c.packages, pkg)
+	c.updateMinLevel()
+}
+
+// PackageLevels 返回全部包级别的副本
+func (l *Logger) PackageLevels() map[string]Level {
+	c := l.core
+	c.levelMu.RLock()
+	defer c.levelMu.RUnlock()
+	levels := make(map[string]Level, len(c.packages))
+	for pkg, level := range c.packages {
+		levels[pkg] = level
+	}
+	return levels
+}
+
+// LevelFor 返回某个包实际使用的最低级别
+func (l *Logger) LevelFor(pkg string) Level {
+	return l.core.levelFor(pkg)
+}
+
+// Enabled 检查指定级别的日志是否可能被输出，包级别比全局级别低时以包级别为准
+func (l *Logger) Enabled(level Level) bool {
+	return level >= Level(l.core.minLevel.Load())
+}
+
+// SetLevelSpec 按 "info,middleware=debug,gorm=warn" 格式设置全局级别和包级别，
+// 不带包名的一项是全局级别，省略时不修改；已有的包级别会被替换
+func (l *Logger) SetLevelSpec(spec string) error {
+	global := l.Level()
+	packages := make(map[string]Level)
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		pkg, name, ok := strings.Cut(item, "=")
+		if !ok {
+			name = pkg
+		}
+		level, err := ParseLevel(name)
+		if err != nil {
+			return err
+		}
+		if ok {
+			packages[strings.TrimSpace(pkg)] = level
+		} else {
+			global = level
+		}
+	}
+
+	c := l.core
+	c.levelMu.Lock()
+	defer c.levelMu.Unlock()
+	c.level.Store(int32(global))
+	c.packages = packages
+	c.updateMinLevel()
+	return nil
+}
+
+// setLevel 修改全局级别
+func (c *loggerCore) setLevel(level Level) {
+	c.levelMu.Lock()
+	defer c.levelMu.Unlock()
+	c.level.Store(int32(level))
+	c.updateMinLevel()
+}
+
+// updateMinLevel 重新计算最低级别，调用方需要持有写锁
+func (c *loggerCore) updateMinLevel() {
+	lowest := Level(c.level.Load())
+	for _, level := range c.packages {
+		if level < lowest {
+			lowest = level
+		}
+	}
+	c.minLevel.Store(int32(lowest))
+}
+
+// levelFor 返回包实际使用的级别
+func (c *loggerCore) levelFor(pkg string) Level {
+	c.levelMu.RLock()
+	defer c.levelMu.RUnlock()
+	if level, ok := c.packages[pkg]; ok {
+		return level
+	}
+	return Level(c.level.Load())
+}
+
+// levelState 是 LevelHandler 读写的 JSON 格式
+type levelState struct {
+	Level    string            `json:"level"`
+	Packages map[string]string `json:"packages,omitempty"`
+}
+
+// levelRequest 是 LevelHandler 接受的修改请求，package 为空时修改全局级别，
+// level 为空时删除该包的级别
+type levelRequest struct {
+	Package string `json:"package"`
+	Level   string `json:"level"`
+}
+
+// LevelHandler 返回在运行时查看和修改日志级别的 HTTP 处理器，只应注册在需要管理员权限的路由上：
+//
+//	GET  返回 {"level":"INFO","packages":{"middleware":"DEBUG"}}
+//	PUT  请求体 {"package":"middleware","level":"debug"}，也可以使用同名的查询参数
+//
+// 在 Gin 中使用 gin.WrapH(minutil.LevelHandler(minutil.GetLogger()))
+func LevelHandler(l *Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPut, http.MethodPost:
+			req := levelRequest{
+				Package: r.URL.Query().Get("package"),
+				Level:   r.URL.Query().Get("level"),
+			}
+			if r.ContentLength != 0 {
+				if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+					http.Error(w, "invalid request body: "+err.Error(), http.StatusBadRequest)
+					return
+				}
+			}
+			if err := applyLevelRequest(l, req); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		default:
+			w.Header().Set("Allow", "GET, PUT, POST")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		state := levelState{Level: l.Level().String(), Packages: make(map[string]string)}
+		for pkg, level := range l.PackageLevels() {
+			state.Packages[pkg] = level.String()
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(state)
+	})
+}
+
+// applyLevelRequest 执行一次级别修改
+func applyLevelRequest(l *Logger, req levelRequest) error {
+	if req.Package != "" && req.Level == "" {
+		l.ResetPackageLevel(req.Package)
+		return nil
+	}
+	level, err := ParseLevel(req.Level)
+	if err != nil {
+		return err
+	}
+	if req.Package == "" {
+		l.SetLevel(level)
+	} else {
+		l.SetPackageLevel(req.Package, level)
+	}
+	l.Log(LevelWarn, "log level changed", "package", req.Package, "level", level)
+	return nil
+}
+
+// ToggleDebugOnSignal 每收到一次 sig 就在 DEBUG 和原来的级别之间切换，packages 为空时切换全局级别，
+// 否则只切换这些包。返回的函数停止监听信号，例如：
+//
+//	stop := minutil.ToggleDebugOnSignal(minutil.GetLogger(), syscall.SIGUSR1, "middleware")
+//	defer stop()
+func ToggleDebugOnSignal(l *Logger, sig os.Signal, packages ...string) (stop func()) {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, sig)
+	done := make(chan struct{})
+	go func() {
+		var saved map[string]Level // 切换到 DEBUG 之前的级别，为 nil 表示当前未切换
+		for {
+			select {
+			case <-ch:
+				saved = toggleDebug(l, packages, saved)
+			case <-done:
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			signal.Stop(ch)
+			close(done)
+		})
+	}
+}
+
+// toggleDebug 切换一次 DEBUG，返回新的保存状态。saved 中的键 "" 表示全局级别，
+// 值为 -1 表示切换前该包没有单独的级别
+func toggleDebug(l *Logger, packages []string, saved map[string]Level) map[string]Level {
+	if saved != nil {
+		for pkg, level := range saved {
+			switch {
+			case pkg == "":
+				l.SetLevel(level)
+			case level < 0:
+				l.ResetPackageLevel(pkg)
+			default:
+				l.SetPackageLevel(pkg, level)
+			}
+		}
+		l.Log(LevelWarn, "debug logging disabled", "packages", describePackages(packages))
+		return nil
+	}
+
+	saved = make(map[string]Level)
+	if len(packages) == 0 {
+		saved[""] = l.Level()
+		l.SetLevel(LevelDebug)
+	} else {
+		current := l.PackageLevels()
+		for _, pkg := range packages {
+			level, ok := current[pkg]
+			if !ok {
+				level = -1
+			}
+			saved[pkg] = level
+			l.SetPackageLevel(pkg, LevelDebug)
+		}
+	}
+	l.Log(LevelWarn, "debug logging enabled", "packages", describePackages(packages))
+	return saved
+}
+
+// describePackages 返回用于日志的包列表
+func describePackages(packages []string) string {
+	if len(packages) == 0 {
+		return "*"
+	}
+	sorted := append([]string(nil), packages...)
+	sort.Strings(sorted)
+	return fmt.Sprint(sorted)
+}
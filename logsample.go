@@ -0,0 +1,219 @@
+package minutil
+
+import (
+	"container/list"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// SampleBy 决定哪些日志被视为相似的日志
+type SampleBy int
+
+const (
+	// SampleByCaller 同一代码位置的日志视为相似，适合消息中带有变量的日志
+	SampleByCaller SampleBy = iota
+	// SampleByMessage 消息相同的日志视为相似，不区分代码位置
+	SampleByMessage
+)
+
+// SamplingPolicy 是一个级别的采样策略：每个 Interval 内每组相似的日志先输出 First 条，
+// 之后每 Thereafter 条输出一条，Thereafter 为 0 时全部丢弃。被丢弃的数量在 Interval 结束时
+// 或调用 Logger.Sync 时汇总为一条 "suppressed N similar messages"
+type SamplingPolicy struct {
+	Interval   time.Duration
+	First      int
+	Thereafter int
+	By         SampleBy
+}
+
+// WithSampling 为级别设置采样策略，用于下游故障时避免同一条错误刷满磁盘，例如：
+//
+//	NewLogger(WithSampling(LevelError, SamplingPolicy{Interval: time.Second, First: 10, Thereafter: 100}))
+//
+// LevelFatal 不会被采样
+func WithSampling(level Level, policy SamplingPolicy) LoggerOption {
+	return func(c *loggerCore) {
+		if level == LevelFatal || policy.Interval <= 0 {
+			return
+		}
+		if c.samplers == nil {
+			c.samplers = make(map[Level]*sampler)
+		}
+		c.samplers[level] = &sampler{
+			policy: policy,
+			write:  c.write,
+			groups: make(map[string]*list.Element),
+			order:  list.New(),
+		}
+	}
+}
+
+// maxSampleGroups 是采样器最多保存的组数，超过后提前结束最早开始的周期
+const maxSampleGroups = 4096
+
+// sampler 按 SamplingPolicy 对一个级别的日志采样
+type sampler struct {
+	policy SamplingPolicy
+	write  func(entry *Entry) // 输出定时器到期时的汇总
+
+	mu      sync.Mutex
+	groups  map[string]*list.Element // 值为 *sampleGroup
+	order   *list.List               // 按周期开始时间排列，最前面的最早结束
+	pending int                      // 有待汇总数量的组数
+	timer   *time.Timer              // 在最早的周期结束时输出汇总，没有待汇总数量时为 nil
+	closed  bool
+}
+
+// sampleGroup 是一组相似日志在当前周期内的计数
+type sampleGroup struct {
+	key        string
+	start      time.Time
+	count      int
+	suppressed int
+	last       Entry // 最近一条被丢弃的日志，用于汇总
+}
+
+// sample 返回日志是否应该输出，以及需要先输出的已结束周期的汇总
+func (s *sampler) sample(entry *Entry) (bool, []*Entry) {
+	key := s.key(entry)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	summaries := s.expire(entry.Time)
+	elem, ok := s.groups[key]
+	if !ok {
+		if s.order.Len() >= maxSampleGroups {
+			if summary := s.remove(s.order.Front(), entry.Time); summary != nil {
+				summaries = append(summaries, summary)
+			}
+		}
+		elem = s.order.PushBack(&sampleGroup{key: key, start: entry.Time})
+		s.groups[key] = elem
+	}
+	g := elem.Value.(*sampleGroup)
+
+	g.count++
+	if g.count <= s.policy.First {
+		return true, summaries
+	}
+	if s.policy.Thereafter > 0 && (g.count-s.policy.First)%s.policy.Thereafter == 0 {
+		return true, summaries
+	}
+	if g.suppressed == 0 {
+		s.pending++
+	}
+	g.suppressed++
+	g.last = *entry
+	s.schedule()
+	return false, summaries
+}
+
+// expire 删除周期已经结束的组，返回它们的汇总，调用方需要持有锁
+func (s *sampler) expire(now time.Time) []*Entry {
+	var summaries []*Entry
+	for elem := s.order.Front(); elem != nil; elem = s.order.Front() {
+		if now.Sub(elem.Value.(*sampleGroup).start) < s.policy.Interval {
+			break
+		}
+		if summary := s.remove(elem, now); summary != nil {
+			summaries = append(summaries, summary)
+		}
+	}
+	return summaries
+}
+
+// remove 删除一个组并返回它的汇总，调用方需要持有锁
+func (s *sampler) remove(elem *list.Element, now time.Time) *Entry {
+	g := s.order.Remove(elem).(*sampleGroup)
+	delete(s.groups, g.key)
+	if g.suppressed > 0 {
+		s.pending--
+	}
+	return g.summary(now)
+}
+
+// schedule 在有待汇总数量时启动定时器，调用方需要持有锁
+func (s *sampler) schedule() {
+	if s.timer != nil || s.closed || s.pending == 0 {
+		return
+	}
+	g := s.order.Front().Value.(*sampleGroup)
+	s.timer = time.AfterFunc(time.Until(g.start.Add(s.policy.Interval)), s.tick)
+}
+
+// tick 输出已结束周期的汇总，日志停止后被丢弃的数量也能及时输出
+func (s *sampler) tick() {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return
+	}
+	s.timer = nil
+	summaries := s.expire(time.Now())
+	s.schedule()
+	s.mu.Unlock()
+	for _, summary := range summaries {
+		s.write(summary)
+	}
+}
+
+// flush 返回全部组尚未输出的汇总，并清空计数
+func (s *sampler) flush(now time.Time) []*Entry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var summaries []*Entry
+	for elem := s.order.Front(); elem != nil; elem = elem.Next() {
+		g := elem.Value.(*sampleGroup)
+		if summary := g.summary(now); summary != nil {
+			summaries = append(summaries, summary)
+			g.suppressed = 0
+		}
+	}
+	s.pending = 0
+	return summaries
+}
+
+// close 停止定时器，之后被丢弃的数量只在 flush 时输出
+func (s *sampler) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+}
+
+// key 返回日志所在的组
+func (s *sampler) key(entry *Entry) string {
+	if s.policy.By == SampleByMessage {
+		return entry.Message
+	}
+	return entry.Caller.Package + "/" + entry.Caller.File + ":" + strconv.Itoa(entry.Caller.Line)
+}
+
+// summary 返回被丢弃日志的汇总，时间为输出汇总的时间，没有被丢弃的日志时返回 nil
+func (g *sampleGroup) summary(now time.Time) *Entry {
+	if g.suppressed == 0 {
+		return nil
+	}
+	return &Entry{
+		Time:    now,
+		Level:   g.last.Level,
+		Message: fmt.Sprintf("suppressed %s similar messages", formatCount(g.suppressed)),
+		Caller:  g.last.Caller,
+		Fields:  []Field{{Key: "sampled_message", Value: g.last.Message}},
+		pc:      g.last.pc,
+	}
+}
+
+// formatCount 格式化数量，每三位加一个逗号，例如 9,532
+func formatCount(n int) string {
+	s := strconv.Itoa(n)
+	for i := len(s) - 3; i > 0; i -= 3 {
+		s = s[:i] + "," + s[i:]
+	}
+	return s
+}
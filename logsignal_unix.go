@@ -0,0 +1,13 @@
+//go:build unix
+
+package minutil
+
+import (
+	"syscall"
+)
+
+// ToggleDebugOnSIGUSR1 每收到一次 SIGUSR1 就在 DEBUG 和原来的级别之间切换，
+// 例如 kill -USR1 <pid> 打开 DEBUG，再发送一次恢复，参见 ToggleDebugOnSignal
+func ToggleDebugOnSIGUSR1(l *Logger, packages ...string) (stop func()) {
+	return ToggleDebugOnSignal(l, syscall.SIGUSR1, packages...)
+}
@@ -0,0 +1,160 @@
+package minutil
+
+import (
+	"context"
+	"log/slog"
+	"runtime"
+	"time"
+)
+
+// slogLevelFatal 是 LevelFatal 在 slog 中对应的级别
+const slogLevelFatal = slog.LevelError + 4
+
+// slogLevel 把 Level 转换为 slog.Level
+func slogLevel(level Level) slog.Level {
+	switch level {
+	case LevelDebug:
+		return slog.LevelDebug
+	case LevelInfo:
+		return slog.LevelInfo
+	case LevelWarn:
+		return slog.LevelWarn
+	case LevelError:
+		return slog.LevelError
+	default:
+		return slogLevelFatal
+	}
+}
+
+// fromSlogLevel 把 slog.Level 转换为 Level，自定义的中间级别向下取整
+func fromSlogLevel(level slog.Level) Level {
+	switch {
+	case level < slog.LevelInfo:
+		return LevelDebug
+	case level < slog.LevelWarn:
+		return LevelInfo
+	case level < slog.LevelError:
+		return LevelWarn
+	case level < slogLevelFatal:
+		return LevelError
+	default:
+		return LevelFatal
+	}
+}
+
+// SlogHandler 是把 slog 日志写入 Logger 的 slog.Handler，输出格式、输出位置和级别都由 Logger 决定，
+// 因此接受 *slog.Logger 的第三方库也能写入同一个日志流：
+//
+//	slog.SetDefault(slog.New(minutil.NewSlogHandler(minutil.GetLogger())))
+type SlogHandler struct {
+	logger *Logger
+	fields []Field
+	prefix string // WithGroup 设置的键前缀
+}
+
+// NewSlogHandler 创建一个写入 l 的 slog.Handler
+func NewSlogHandler(l *Logger) *SlogHandler {
+	return &SlogHandler{logger: l}
+}
+
+// Enabled 按 Logger 的最低级别过滤
+func (h *SlogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return h.logger.Enabled(fromSlogLevel(level))
+}
+
+// Handle 把 slog.Record 转换为一条日志，context 中的请求 ID 和 trace ID 会作为字段写入
+func (h *SlogHandler) Handle(ctx context.Context, r slog.Record) error {
+	fields := append([]Field(nil), h.logger.fields...)
+	fields = appendFields(fields, contextFields(ctx))
+	fields = append(fields, h.fields...)
+	r.Attrs(func(a slog.Attr) bool {
+		fields = appendAttr(fields, h.prefix, a)
+		return true
+	})
+
+	entry := &Entry{
+		Time:    r.Time,
+		Level:   fromSlogLevel(r.Level),
+		Message: r.Message,
+		Fields:  fields,
+		pc:      r.PC,
+	}
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+	if r.PC != 0 {
+		frame, _ := runtime.CallersFrames([]uintptr{r.PC}).Next()
+		entry.Caller = newCaller(frame.File, frame.Line, frame.Function)
+	}
+	if entry.Level < h.logger.core.levelFor(entry.Caller.Package) {
+		return nil
+	}
+	h.logger.emit(entry)
+	return nil
+}
+
+// WithAttrs 返回带有额外字段的 Handler
+func (h *SlogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	h2 := *h
+	h2.fields = append([]Field(nil), h.fields...)
+	for _, a := range attrs {
+		h2.fields = appendAttr(h2.fields, h.prefix, a)
+	}
+	return &h2
+}
+
+// WithGroup 返回之后的字段都带有 name 前缀的 Handler
+func (h *SlogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := *h
+	h2.prefix = h.prefix + name + "."
+	return &h2
+}
+
+// appendAttr 把 slog.Attr 转换为字段，分组展开为以点分隔的键
+func appendAttr(fields []Field, prefix string, a slog.Attr) []Field {
+	v := a.Value.Resolve()
+	if v.Kind() == slog.KindGroup {
+		if a.Key != "" {
+			prefix += a.Key + "."
+		}
+		for _, ga := range v.Group() {
+			fields = appendAttr(fields, prefix, ga)
+		}
+		return fields
+	}
+	if a.Key == "" {
+		return fields
+	}
+	return append(fields, Field{Key: prefix + a.Key, Value: v.Any()})
+}
+
+// WithSlogHandler 增加一个把日志转交给 h 的输出，例如 slog.NewJSONHandler 或第三方的 Handler
+func WithSlogHandler(h slog.Handler) LoggerOption {
+	return func(c *loggerCore) {
+		c.sinks = append(c.sinks, sink{handler: h})
+	}
+}
+
+// NewSlogLogger 创建一个只写入 h 的 Logger，配合 SetLogger 可以让包级的 Info、Error 等函数写入任意 slog.Handler
+func NewSlogLogger(h slog.Handler) *Logger {
+	l := NewLogger(WithSlogHandler(h))
+	l.core.sinks = l.core.sinks[1:]
+	return l
+}
+
+// handleSlog 把一条日志转换为 slog.Record 交给 h
+func handleSlog(h slog.Handler, entry *Entry) {
+	level := slogLevel(entry.Level)
+	ctx := context.Background()
+	if !h.Enabled(ctx, level) {
+		return
+	}
+	r := slog.NewRecord(entry.Time, level, entry.Message, entry.pc)
+	for _, f := range entry.Fields {
+		r.AddAttrs(slog.Any(f.Key, f.Value))
+	}
+	h.Handle(ctx, r)
+}
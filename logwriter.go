@@ -0,0 +1,367 @@
+package minutil
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ColorEnabled 检查输出是否适合带颜色：输出是终端，并且没有设置环境变量 NO_COLOR
+func ColorEnabled(w io.Writer) bool {
+	if _, ok := os.LookupEnv("NO_COLOR"); ok {
+		return false
+	}
+	return IsTerminal(w)
+}
+
+// IsTerminal 检查输出是否为终端
+func IsTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// rotatedTimeFormat 是轮转后文件名中的时间格式
+const rotatedTimeFormat = "20060102T150405.000"
+
+// RotatingFile 是按大小或时间自动轮转的日志文件。
+// 轮转时当前文件被重命名为 name-时间.ext（同一毫秒内多次轮转时为 name-时间-序号.ext），并按 MaxBackups 和 MaxAge 删除旧文件
+type RotatingFile struct {
+	mu         sync.Mutex
+	filename   string
+	maxSize    int64
+	interval   time.Duration
+	maxBackups int
+	maxAge     time.Duration
+
+	file     *os.File
+	size     int64
+	openedAt time.Time
+}
+
+// RotatingFileOption 是 RotatingFile 的配置项
+type RotatingFileOption func(*RotatingFile)
+
+// WithMaxSize 设置单个文件的最大字节数，超过后轮转，为 0 时不按大小轮转
+func WithMaxSize(bytes int64) RotatingFileOption {
+	return func(f *RotatingFile) {
+		f.maxSize = bytes
+	}
+}
+
+// WithRotateInterval 设置按时间轮转的间隔，例如 24 * time.Hour 表示每天零点（UTC）轮转
+func WithRotateInterval(interval time.Duration) RotatingFileOption {
+	return func(f *RotatingFile) {
+		f.interval = interval
+	}
+}
+
+// WithMaxBackups 设置最多保留的旧文件数量，为 0 时不限制
+func WithMaxBackups(n int) RotatingFileOption {
+	return func(f *RotatingFile) {
+		f.maxBackups = n
+	}
+}
+
+// WithMaxAge 设置旧文件的最长保留时间，为 0 时不限制
+func WithMaxAge(age time.Duration) RotatingFileOption {
+	return func(f *RotatingFile) {
+		f.maxAge = age
+	}
+}
+
+// NewRotatingFile 打开或创建日志文件，所在目录不存在时自动创建
+func NewRotatingFile(filename string, opts ...RotatingFileOption) (*RotatingFile, error) {
+	f := &RotatingFile{filename: filename}
+	for _, opt := range opts {
+		opt(f)
+	}
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return nil, err
+	}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Write 写入日志，需要时先轮转文件
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.file == nil {
+		return 0, os.ErrClosed
+	}
+	if f.shouldRotate(int64(len(p)), time.Now()) {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// Rotate 立即轮转文件
+func (f *RotatingFile) Rotate() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.rotate()
+}
+
+// Sync 把文件内容写入磁盘
+func (f *RotatingFile) Sync() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.file == nil {
+		return nil
+	}
+	return f.file.Sync()
+}
+
+// Close 关闭文件
+func (f *RotatingFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
+
+// shouldRotate 检查写入 n 字节前是否需要轮转，空文件不会因为大小轮转
+func (f *RotatingFile) shouldRotate(n int64, now time.Time) bool {
+	if f.maxSize > 0 && f.size > 0 && f.size+n > f.maxSize {
+		return true
+	}
+	return f.interval > 0 && !now.Truncate(f.interval).Equal(f.openedAt.Truncate(f.interval))
+}
+
+// open 以追加方式打开文件
+func (f *RotatingFile) open() error {
+	file, err := os.OpenFile(f.filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	f.file = file
+	f.size = info.Size()
+	f.openedAt = time.Now()
+	if f.interval > 0 && info.Size() > 0 {
+		// 沿用已有文件时按它的修改时间判断是否跨过了轮转时间点
+		f.openedAt = info.ModTime()
+	}
+	return nil
+}
+
+// rotate 关闭当前文件，重命名后打开新文件，并清理旧文件
+func (f *RotatingFile) rotate() error {
+	if f.file != nil {
+		if err := f.file.Close(); err != nil {
+			return err
+		}
+		f.file = nil
+	}
+	backup, err := f.backupName(time.Now())
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(f.filename, backup); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if err := f.open(); err != nil {
+		return err
+	}
+	f.removeOldBackups()
+	return nil
+}
+
+// backupName 返回一个还不存在的旧文件名，os.Rename 会覆盖已有文件，所以同一毫秒内的轮转需要加上序号
+func (f *RotatingFile) backupName(now time.Time) (string, error) {
+	ext := filepath.Ext(f.filename)
+	stem := strings.TrimSuffix(f.filename, ext) + "-" + now.Format(rotatedTimeFormat)
+	name := stem + ext
+	for seq := 1; ; seq++ {
+		if _, err := os.Lstat(name); errors.Is(err, os.ErrNotExist) {
+			return name, nil
+		} else if err != nil {
+			return "", err
+		}
+		name = fmt.Sprintf("%s-%d%s", stem, seq, ext)
+	}
+}
+
+// parseBackupName 从旧文件名中去掉前缀和扩展名后的部分解析出轮转时间和序号
+func parseBackupName(s string) (time.Time, int, bool) {
+	if at, err := time.ParseInLocation(rotatedTimeFormat, s, time.Local); err == nil {
+		return at, 0, true
+	}
+	i := strings.LastIndexByte(s, '-')
+	if i < 0 {
+		return time.Time{}, 0, false
+	}
+	seq, err := strconv.Atoi(s[i+1:])
+	if err != nil || seq <= 0 {
+		return time.Time{}, 0, false
+	}
+	at, err := time.ParseInLocation(rotatedTimeFormat, s[:i], time.Local)
+	if err != nil {
+		return time.Time{}, 0, false
+	}
+	return at, seq, true
+}
+
+// removeOldBackups 按数量和时间删除旧文件，删除失败时下次轮转再试
+func (f *RotatingFile) removeOldBackups() {
+	if f.maxBackups <= 0 && f.maxAge <= 0 {
+		return
+	}
+	ext := filepath.Ext(f.filename)
+	prefix := strings.TrimSuffix(filepath.Base(f.filename), ext) + "-"
+	entries, err := os.ReadDir(filepath.Dir(f.filename))
+	if err != nil {
+		return
+	}
+
+	type backup struct {
+		path string
+		at   time.Time
+		seq  int
+	}
+	var backups []backup
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, ext) {
+			continue
+		}
+		at, seq, ok := parseBackupName(strings.TrimSuffix(strings.TrimPrefix(name, prefix), ext))
+		if !ok {
+			continue
+		}
+		backups = append(backups, backup{path: filepath.Join(filepath.Dir(f.filename), name), at: at, seq: seq})
+	}
+	sort.Slice(backups, func(i, j int) bool {
+		if !backups[i].at.Equal(backups[j].at) {
+			return backups[i].at.After(backups[j].at)
+		}
+		return backups[i].seq > backups[j].seq
+	})
+
+	now := time.Now()
+	for i, b := range backups {
+		if (f.maxBackups > 0 && i >= f.maxBackups) || (f.maxAge > 0 && now.Sub(b.at) > f.maxAge) {
+			os.Remove(b.path)
+		}
+	}
+}
+
+// DefaultAsyncBufferSize 是 AsyncWriter 默认可以缓冲的日志条数
+const DefaultAsyncBufferSize = 1024
+
+// AsyncWriter 在后台协程中写入日志，调用方不会被慢速的输出阻塞。
+// 缓冲已满时 Write 会等待，日志不会丢失；程序退出前需要调用 Sync 或 Close
+type AsyncWriter struct {
+	w       io.Writer
+	ch      chan []byte
+	flush   chan chan struct{}
+	done    chan struct{}
+	closeMu sync.RWMutex
+	closed  bool
+}
+
+// NewAsyncWriter 创建一个 AsyncWriter，size 为可以缓冲的日志条数，小于等于 0 时使用 DefaultAsyncBufferSize
+func NewAsyncWriter(w io.Writer, size int) *AsyncWriter {
+	if size <= 0 {
+		size = DefaultAsyncBufferSize
+	}
+	a := &AsyncWriter{
+		w:     w,
+		ch:    make(chan []byte, size),
+		flush: make(chan chan struct{}),
+		done:  make(chan struct{}),
+	}
+	go a.run()
+	return a
+}
+
+// Write 把 p 的副本放入缓冲
+func (a *AsyncWriter) Write(p []byte) (int, error) {
+	a.closeMu.RLock()
+	defer a.closeMu.RUnlock()
+	if a.closed {
+		return 0, os.ErrClosed
+	}
+	a.ch <- append([]byte(nil), p...)
+	return len(p), nil
+}
+
+// Sync 等待缓冲中的日志全部写入，底层输出支持 Sync 时一并调用
+func (a *AsyncWriter) Sync() error {
+	a.closeMu.RLock()
+	defer a.closeMu.RUnlock()
+	if a.closed {
+		return nil
+	}
+	ack := make(chan struct{})
+	a.flush <- ack
+	<-ack
+	if syncer, ok := a.w.(interface{ Sync() error }); ok {
+		return syncer.Sync()
+	}
+	return nil
+}
+
+// Close 写入缓冲中的日志后停止后台协程，底层输出支持 Close 时一并关闭
+func (a *AsyncWriter) Close() error {
+	a.closeMu.Lock()
+	if a.closed {
+		a.closeMu.Unlock()
+		return nil
+	}
+	a.closed = true
+	close(a.ch)
+	a.closeMu.Unlock()
+	<-a.done
+	if closer, ok := a.w.(io.Closer); ok && a.w != os.Stdout && a.w != os.Stderr {
+		return closer.Close()
+	}
+	return nil
+}
+
+func (a *AsyncWriter) run() {
+	defer close(a.done)
+	for {
+		select {
+		case p, ok := <-a.ch:
+			if !ok {
+				return
+			}
+			a.w.Write(p)
+		case ack := <-a.flush:
+			// 写完 flush 请求之前已经进入缓冲的日志
+			for n := len(a.ch); n > 0; n-- {
+				a.w.Write(<-a.ch)
+			}
+			close(ack)
+		}
+	}
+}
@@ -1,44 +1,288 @@
 package minutil
 
 import (
-	"log"
 	"sync"
+	"time"
 )
 
-// MapTokenStore 是基于内存的 Token 存储实现
+// DefaultSweepInterval 是 MapTokenStore 清理过期记录的默认间隔
+const DefaultSweepInterval = time.Minute
+
+// MapTokenStore 是基于内存的 Token 存储实现，过期的记录由后台协程定期清理
 type MapTokenStore struct {
-	mu    sync.RWMutex
-	store map[string]bool
+	mu       sync.RWMutex
+	store    map[string]tokenEntry
+	refresh  map[string]*refreshEntry
+	families map[string]time.Time
+	users    map[string]time.Time
+	sessions map[string]*sessionEntry
+	byUser   map[string]map[string]struct{} // 用户 ID 到会话 ID 的索引
+	stop     chan struct{}
+	once     sync.Once
+}
+
+// tokenEntry 记录 Token 的失效状态
+type tokenEntry struct {
+	invalid   bool
+	expiresAt time.Time
+}
+
+// refreshEntry 记录刷新 Token 的使用状态
+type refreshEntry struct {
+	used      bool
+	expiresAt time.Time
+}
+
+// sessionEntry 记录会话及其过期时间
+type sessionEntry struct {
+	session   Session
+	expiresAt time.Time
 }
 
-// NewMapTokenStore 创建一个新的 MapTokenStore
-func NewMapTokenStore() *MapTokenStore {
-	log.Println("Creating new MapTokenStore")
-	return &MapTokenStore{
-		store: make(map[string]bool),
+// MapTokenStoreOption 是 MapTokenStore 的配置项
+type MapTokenStoreOption func(*mapTokenStoreConfig)
+
+type mapTokenStoreConfig struct {
+	sweepInterval time.Duration
+}
+
+// WithSweepInterval 设置清理过期记录的间隔，小于等于 0 时不启动后台清理
+func WithSweepInterval(interval time.Duration) MapTokenStoreOption {
+	return func(c *mapTokenStoreConfig) {
+		c.sweepInterval = interval
 	}
 }
 
+// NewMapTokenStore 创建一个新的 MapTokenStore，不再使用时调用 Close 停止后台清理
+func NewMapTokenStore(opts ...MapTokenStoreOption) *MapTokenStore {
+	cfg := mapTokenStoreConfig{sweepInterval: DefaultSweepInterval}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	m := &MapTokenStore{
+		store:    make(map[string]tokenEntry),
+		refresh:  make(map[string]*refreshEntry),
+		families: make(map[string]time.Time),
+		users:    make(map[string]time.Time),
+		sessions: make(map[string]*sessionEntry),
+		byUser:   make(map[string]map[string]struct{}),
+		stop:     make(chan struct{}),
+	}
+	if cfg.sweepInterval > 0 {
+		go m.sweepLoop(cfg.sweepInterval)
+	}
+	return m
+}
+
 // Get 获取 Token 的状态
-func (m *MapTokenStore) Get(token string) (bool, error) {
+func (m *MapTokenStore) Get(id string) (bool, error) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
-	log.Printf("Getting token status for token: %s", token)
-	val, ok := m.store[token]
-	if !ok {
-		log.Printf("Token %s not found", token)
+	val, ok := m.store[id]
+	if !ok || expired(val.expiresAt, time.Now()) {
 		return false, nil
 	}
-	log.Printf("Token %s found, status: %v", token, val)
-	return val, nil
+	return val.invalid, nil
+}
+
+// Set 设置 Token 的状态，expiration 之后记录自动删除，为 0 时永久保存
+func (m *MapTokenStore) Set(id string, invalid bool, expiration time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.store[id] = tokenEntry{invalid: invalid, expiresAt: deadline(expiration)}
+	return nil
+}
+
+// SaveRefreshToken 记录一个尚未使用的刷新 Token
+func (m *MapTokenStore) SaveRefreshToken(id string, expiration time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.refresh[id] = &refreshEntry{expiresAt: deadline(expiration)}
+	return nil
+}
+
+// UseRefreshToken 把刷新 Token 标记为已使用，返回它之前是否已被使用
+func (m *MapTokenStore) UseRefreshToken(id string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entry, ok := m.refresh[id]
+	if !ok || expired(entry.expiresAt, time.Now()) {
+		delete(m.refresh, id)
+		return false, ErrRefreshTokenNotFound
+	}
+	used := entry.used
+	entry.used = true
+	return used, nil
+}
+
+// RevokeFamily 撤销整条刷新链
+func (m *MapTokenStore) RevokeFamily(family string, expiration time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.families[family] = deadline(expiration)
+	return nil
+}
+
+// IsFamilyRevoked 检查刷新链是否已被撤销
+func (m *MapTokenStore) IsFamilyRevoked(family string) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	until, ok := m.families[family]
+	return ok && !expired(until, time.Now()), nil
+}
+
+// SetUserNotBefore 设置用户的失效水位线
+func (m *MapTokenStore) SetUserNotBefore(userID string, notBefore time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.users[userID] = notBefore
+	return nil
+}
+
+// GetUserNotBefore 返回用户的失效水位线
+func (m *MapTokenStore) GetUserNotBefore(userID string) (time.Time, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.users[userID], nil
+}
+
+// SaveSession 创建或覆盖会话
+func (m *MapTokenStore) SaveSession(session *Session, expiration time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if expiration <= 0 {
+		m.deleteSession(session.ID)
+		return nil
+	}
+	m.sessions[session.ID] = &sessionEntry{session: *session, expiresAt: deadline(expiration)}
+	ids, ok := m.byUser[session.UserID]
+	if !ok {
+		ids = make(map[string]struct{})
+		m.byUser[session.UserID] = ids
+	}
+	ids[session.ID] = struct{}{}
+	return nil
+}
+
+// GetSession 返回会话，不存在时返回 nil
+func (m *MapTokenStore) GetSession(id string) (*Session, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	entry, ok := m.sessions[id]
+	if !ok || expired(entry.expiresAt, time.Now()) {
+		return nil, nil
+	}
+	session := entry.session
+	return &session, nil
 }
 
-// Set 设置 Token 的状态
-func (m *MapTokenStore) Set(token string, invalid bool) error {
+// ListSessions 返回用户的全部有效会话
+func (m *MapTokenStore) ListSessions(userID string) ([]*Session, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	now := time.Now()
+	sessions := make([]*Session, 0, len(m.byUser[userID]))
+	for id := range m.byUser[userID] {
+		entry, ok := m.sessions[id]
+		if !ok || expired(entry.expiresAt, now) {
+			continue
+		}
+		session := entry.session
+		sessions = append(sessions, &session)
+	}
+	return sessions, nil
+}
+
+// TouchSession 更新会话的最近访问时间
+func (m *MapTokenStore) TouchSession(id string, lastSeen time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if entry, ok := m.sessions[id]; ok {
+		entry.session.LastSeen = lastSeen
+	}
+	return nil
+}
+
+// DeleteSession 删除会话
+func (m *MapTokenStore) DeleteSession(id string) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	log.Printf("Setting token status for token: %s, invalid: %v", token, invalid)
-	m.store[token] = invalid
-	log.Printf("Token %s status set to %v", token, invalid)
+	m.deleteSession(id)
 	return nil
-}
\ No newline at end of file
+}
+
+// deleteSession 删除会话及其索引，调用方需要持有写锁
+func (m *MapTokenStore) deleteSession(id string) {
+	entry, ok := m.sessions[id]
+	if !ok {
+		return
+	}
+	delete(m.sessions, id)
+	if ids, ok := m.byUser[entry.session.UserID]; ok {
+		delete(ids, id)
+		if len(ids) == 0 {
+			delete(m.byUser, entry.session.UserID)
+		}
+	}
+}
+
+// Sweep 立即删除所有过期的记录
+func (m *MapTokenStore) Sweep() {
+	now := time.Now()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for k, v := range m.store {
+		if expired(v.expiresAt, now) {
+			delete(m.store, k)
+		}
+	}
+	for k, v := range m.refresh {
+		if expired(v.expiresAt, now) {
+			delete(m.refresh, k)
+		}
+	}
+	for k, v := range m.families {
+		if expired(v, now) {
+			delete(m.families, k)
+		}
+	}
+	for k, v := range m.sessions {
+		if expired(v.expiresAt, now) {
+			m.deleteSession(k)
+		}
+	}
+}
+
+// Close 停止后台清理协程
+func (m *MapTokenStore) Close() error {
+	m.once.Do(func() {
+		close(m.stop)
+	})
+	return nil
+}
+
+func (m *MapTokenStore) sweepLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			m.Sweep()
+		case <-m.stop:
+			return
+		}
+	}
+}
+
+// deadline 把有效期转换为过期时间，0 表示永不过期
+func deadline(expiration time.Duration) time.Time {
+	if expiration <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(expiration)
+}
+
+// expired 检查过期时间是否已到，零值表示永不过期
+func expired(expiresAt time.Time, now time.Time) bool {
+	return !expiresAt.IsZero() && !now.Before(expiresAt)
+}
@@ -0,0 +1,185 @@
+package middleware
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	minutil "github.com/yowaimono/min-util"
+)
+
+// AccessLogFormat 是访问日志的格式
+type AccessLogFormat int
+
+const (
+	// AccessLogCombined 把请求写成 Apache Combined 格式的一行消息，后面附加耗时和请求 ID
+	AccessLogCombined AccessLogFormat = iota
+	// AccessLogJSON 把请求的各项写成结构化字段，配合 minutil.JSONEncoder 输出为一行 JSON
+	AccessLogJSON
+)
+
+// accessLogConfig 是 AccessLog 中间件的配置
+type accessLogConfig struct {
+	logger *minutil.Logger
+	format AccessLogFormat
+	skip   map[string]struct{}
+	slow   time.Duration
+	redact map[string]struct{} // 值需要隐藏的查询参数，键为小写
+}
+
+// redactedValue 替换敏感查询参数的值
+const redactedValue = "REDACTED"
+
+// AccessLogOption 是 AccessLog 中间件的配置项
+type AccessLogOption func(*accessLogConfig)
+
+// WithAccessLogger 设置写入访问日志的记录器，默认为 minutil.GetLogger()
+func WithAccessLogger(logger *minutil.Logger) AccessLogOption {
+	return func(c *accessLogConfig) {
+		c.logger = logger
+	}
+}
+
+// WithAccessLogFormat 设置访问日志的格式，默认为 AccessLogCombined
+func WithAccessLogFormat(format AccessLogFormat) AccessLogOption {
+	return func(c *accessLogConfig) {
+		c.format = format
+	}
+}
+
+// WithSkipPaths 设置不记录访问日志的路径，例如健康检查 /healthz
+func WithSkipPaths(paths ...string) AccessLogOption {
+	return func(c *accessLogConfig) {
+		for _, p := range paths {
+			c.skip[p] = struct{}{}
+		}
+	}
+}
+
+// WithSlowThreshold 设置慢请求的阈值，耗时超过阈值的请求以 WARN 级别记录
+func WithSlowThreshold(threshold time.Duration) AccessLogOption {
+	return func(c *accessLogConfig) {
+		c.slow = threshold
+	}
+}
+
+// WithRedactedQuery 增加值需要隐藏的查询参数，不区分大小写。
+// 默认隐藏 token 和 access_token，使用 WithAuthQuery 的其他参数名时需要在这里加上
+func WithRedactedQuery(names ...string) AccessLogOption {
+	return func(c *accessLogConfig) {
+		for _, name := range names {
+			c.redact[strings.ToLower(name)] = struct{}{}
+		}
+	}
+}
+
+// redactQuery 把敏感查询参数的值替换为 REDACTED，其余部分保持原样
+func (c *accessLogConfig) redactQuery(rawQuery string) string {
+	if rawQuery == "" {
+		return ""
+	}
+	params := strings.Split(rawQuery, "&")
+	for i, param := range params {
+		key, _, _ := strings.Cut(param, "=")
+		if name, err := url.QueryUnescape(key); err == nil {
+			key = name
+		}
+		if _, ok := c.redact[strings.ToLower(key)]; ok {
+			params[i] = url.QueryEscape(key) + "=" + redactedValue
+		}
+	}
+	return strings.Join(params, "&")
+}
+
+// AccessLog 是一个 Gin 中间件，每个请求结束后通过 minutil.Logger 写一条访问日志，
+// 用来替代 gin.Logger()。5xx 响应以 ERROR 级别记录，慢请求以 WARN 级别记录，其余为 INFO。
+// 需要注册在 RequestID 之后才能记录请求 ID，用户 ID 来自 Auth 保存的声明。
+// 查询参数中的 Token 等敏感值参见 WithRedactedQuery
+func AccessLog(opts ...AccessLogOption) gin.HandlerFunc {
+	cfg := &accessLogConfig{
+		skip:   make(map[string]struct{}),
+		redact: map[string]struct{}{"token": {}, "access_token": {}},
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return func(c *gin.Context) {
+		if _, ok := cfg.skip[c.Request.URL.Path]; ok {
+			c.Next()
+			return
+		}
+		start := time.Now()
+		c.Next()
+		latency := time.Since(start)
+
+		logger := cfg.logger
+		if logger == nil {
+			logger = minutil.GetLogger()
+		}
+		status := c.Writer.Status()
+		level := minutil.LevelInfo
+		switch {
+		case status >= 500:
+			level = minutil.LevelError
+		case cfg.slow > 0 && latency > cfg.slow:
+			level = minutil.LevelWarn
+		}
+		if !logger.Enabled(level) {
+			return
+		}
+
+		requestID := GetRequestID(c)
+		if requestID == "" {
+			requestID = minutil.RequestIDFromContext(c)
+		}
+		userID := minutil.GetUserID(c)
+		size := c.Writer.Size()
+		if size < 0 {
+			size = 0
+		}
+		query := cfg.redactQuery(c.Request.URL.RawQuery)
+
+		if cfg.format == AccessLogJSON {
+			kv := []interface{}{
+				"method", c.Request.Method,
+				"path", c.Request.URL.Path,
+				"query", query,
+				"status", status,
+				"latency", latency,
+				"bytes", size,
+				"client_ip", c.ClientIP(),
+				"user_agent", c.Request.UserAgent(),
+			}
+			if userID != "" {
+				kv = append(kv, "user_id", userID)
+			}
+			if requestID != "" {
+				kv = append(kv, "request_id", requestID)
+			}
+			if errs := c.Errors.ByType(gin.ErrorTypePrivate).String(); errs != "" {
+				kv = append(kv, "errors", strings.TrimSpace(errs))
+			}
+			logger.Log(level, "request", kv...)
+			return
+		}
+
+		user := userID
+		if user == "" {
+			user = "-"
+		}
+		uri := c.Request.URL.EscapedPath()
+		if query != "" {
+			uri += "?" + query
+		}
+		line := fmt.Sprintf(`%s - %s [%s] "%s %s %s" %d %d %q %q %s`,
+			c.ClientIP(), user, start.Format("02/Jan/2006:15:04:05 -0700"),
+			c.Request.Method, uri, c.Request.Proto,
+			status, size, c.Request.Referer(), c.Request.UserAgent(), latency)
+		if requestID != "" {
+			line += " " + requestID
+		}
+		logger.Log(level, line)
+	}
+}
@@ -0,0 +1,91 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	minutil "github.com/yowaimono/min-util"
+)
+
+func TestAccessLog(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var buf bytes.Buffer
+	logger := minutil.NewLogger(minutil.WithOutput(&buf), minutil.WithEncoder(&minutil.JSONEncoder{}))
+	router := gin.New()
+	router.Use(RequestID(), AccessLog(WithAccessLogger(logger), WithAccessLogFormat(AccessLogJSON), WithSkipPaths("/healthz"), WithSlowThreshold(10*time.Millisecond)))
+	router.GET("/healthz", func(c *gin.Context) { c.Status(http.StatusOK) })
+	router.GET("/orders", func(c *gin.Context) { c.String(http.StatusOK, "ok") })
+	router.GET("/slow", func(c *gin.Context) {
+		time.Sleep(20 * time.Millisecond)
+		c.Status(http.StatusOK)
+	})
+
+	for _, path := range []string{"/healthz", "/orders?page=2", "/slow"} {
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		r.Header.Set(RequestIDHeader, "rid-"+strings.TrimPrefix(path, "/"))
+		router.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 access log lines, got %d: %q", len(lines), buf.String())
+	}
+	var entry map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatalf("Expected a JSON line, got %q", lines[0])
+	}
+	if entry["level"] != "INFO" || entry["path"] != "/orders" || entry["query"] != "page=2" || entry["status"] != float64(200) || entry["bytes"] != float64(2) || entry["request_id"] != "rid-orders?page=2" {
+		t.Errorf("Unexpected access log entry: %v", entry)
+	}
+	if !strings.Contains(lines[1], `"level":"WARN"`) {
+		t.Errorf("Expected slow request to be logged at WARN, got %q", lines[1])
+	}
+}
+
+func TestAccessLogCombined(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var buf bytes.Buffer
+	logger := minutil.NewLogger(minutil.WithOutput(&buf))
+	router := gin.New()
+	router.Use(AccessLog(WithAccessLogger(logger)))
+	router.GET("/fail", func(c *gin.Context) { c.String(http.StatusInternalServerError, "boom") })
+
+	r := httptest.NewRequest(http.MethodGet, "/fail?x=1", nil)
+	r.Header.Set("User-Agent", "curl/8.0")
+	router.ServeHTTP(httptest.NewRecorder(), r)
+
+	line := buf.String()
+	if !strings.Contains(line, "[ERROR]") || !strings.Contains(line, `- - [`) || !strings.Contains(line, `"GET /fail?x=1 HTTP/1.1" 500 4 "" "curl/8.0"`) {
+		t.Errorf("Unexpected combined access log: %q", line)
+	}
+}
+
+func TestAccessLogRedactsQuery(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	for name, format := range map[string]AccessLogFormat{"Combined": AccessLogCombined, "JSON": AccessLogJSON} {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			logger := minutil.NewLogger(minutil.WithOutput(&buf), minutil.WithEncoder(&minutil.JSONEncoder{}))
+			router := gin.New()
+			router.Use(AccessLog(WithAccessLogger(logger), WithAccessLogFormat(format), WithRedactedQuery("api_key")))
+			router.GET("/events", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+			r := httptest.NewRequest(http.MethodGet, "/events?token=secret-token&Access_Token=secret-access&api_key=secret-key&page=2", nil)
+			router.ServeHTTP(httptest.NewRecorder(), r)
+
+			out := buf.String()
+			if strings.Contains(out, "secret") {
+				t.Errorf("Expected query secrets to be redacted, got %q", out)
+			}
+			if !strings.Contains(out, "token=REDACTED") || !strings.Contains(out, "page=2") {
+				t.Errorf("Expected redacted and other query parameters to be kept, got %q", out)
+			}
+		})
+	}
+}
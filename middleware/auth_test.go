@@ -0,0 +1,114 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	minutil "github.com/yowaimono/min-util"
+	"github.com/yowaimono/min-util/req"
+)
+
+func TestAuth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tokenManager := minutil.NewJWTTokenManager(minutil.NewKeyRing(minutil.NewHMACKey("k1", []byte("my_secret_key"))), minutil.NewMapTokenStore())
+
+	router := gin.New()
+	router.GET("/me", Auth(tokenManager, WithAuthCookie("token")), func(c *gin.Context) {
+		minutil.OK(c, minutil.GetUserID(c))
+	})
+
+	do := func(setup func(r *http.Request)) minutil.Req[string] {
+		r := httptest.NewRequest(http.MethodGet, "/me", nil)
+		setup(r)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		var resp minutil.Req[string]
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("Failed to decode response %q: %v", w.Body.String(), err)
+		}
+		return resp
+	}
+
+	token, err := tokenManager.GenerateToken("123456", "testuser", time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+	if resp := do(func(r *http.Request) { r.Header.Set("Authorization", "Bearer "+token) }); resp.Data != "123456" {
+		t.Errorf("Expected user ID from bearer token, got %+v", resp)
+	}
+	if resp := do(func(r *http.Request) { r.AddCookie(&http.Cookie{Name: "token", Value: token}) }); resp.Data != "123456" {
+		t.Errorf("Expected user ID from cookie, got %+v", resp)
+	}
+	if resp := do(func(r *http.Request) {}); resp.Code != int(req.ErrTokenInvalid) {
+		t.Errorf("Expected ErrTokenInvalid without token, got %+v", resp)
+	}
+
+	expired, err := tokenManager.GenerateToken("123456", "testuser", -time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+	if resp := do(func(r *http.Request) { r.Header.Set("Authorization", "Bearer "+expired) }); resp.Code != int(req.ErrTokenExpired) {
+		t.Errorf("Expected ErrTokenExpired, got %+v", resp)
+	}
+}
+
+func TestRequirePermission(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tokenManager := minutil.NewJWTTokenManager(minutil.NewKeyRing(minutil.NewHMACKey("k1", []byte("my_secret_key"))), minutil.NewMapTokenStore())
+	policy, err := minutil.LoadPolicy(strings.NewReader(`{"roles": {"admin": ["order:*"], "viewer": ["order:read"]}}`))
+	if err != nil {
+		t.Fatalf("Failed to load policy: %v", err)
+	}
+
+	router := gin.New()
+	router.DELETE("/orders", Auth(tokenManager), RequirePolicy(policy, "order:delete"), func(c *gin.Context) {
+		minutil.OK(c, "deleted")
+	})
+	router.GET("/admin", Auth(tokenManager), RequireRole("admin"), func(c *gin.Context) {
+		minutil.OK(c, "admin")
+	})
+	router.GET("/settings", Auth(tokenManager), RequirePermission("settings:write"), func(c *gin.Context) {
+		minutil.OK(c, "settings")
+	})
+
+	do := func(method, path string, roles ...string) int {
+		token, err := tokenManager.GenerateToken("123456", "testuser", time.Hour, minutil.WithRoles(roles...))
+		if err != nil {
+			t.Fatalf("Failed to generate token: %v", err)
+		}
+		r := httptest.NewRequest(method, path, nil)
+		r.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		var resp minutil.Req[string]
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("Failed to decode response %q: %v", w.Body.String(), err)
+		}
+		return resp.Code
+	}
+
+	if code := do(http.MethodDelete, "/orders", "admin"); code != 200 {
+		t.Errorf("Expected admin to delete orders, got %d", code)
+	}
+	if code := do(http.MethodDelete, "/orders", "viewer"); code != int(req.ErrPermissionDenied) {
+		t.Errorf("Expected viewer to be denied, got %d", code)
+	}
+	if code := do(http.MethodGet, "/admin", "viewer"); code != int(req.ErrPermissionDenied) {
+		t.Errorf("Expected viewer to be denied, got %d", code)
+	}
+
+	// 路由注册之后替换默认策略同样生效
+	defer minutil.SetDefaultPolicy(minutil.DefaultPolicy())
+	if code := do(http.MethodGet, "/settings", "admin"); code != int(req.ErrPermissionDenied) {
+		t.Errorf("Expected admin to be denied by the empty default policy, got %d", code)
+	}
+	minutil.SetDefaultPolicy(minutil.NewPolicy().Grant("admin", "*"))
+	if code := do(http.MethodGet, "/settings", "admin"); code != 200 {
+		t.Errorf("Expected admin to be allowed after SetDefaultPolicy, got %d", code)
+	}
+}
@@ -0,0 +1,309 @@
+package middleware
+
+import (
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// concurrencyConfig 是 ConcurrencyLimit 和 LoadShedding 中间件的配置
+type concurrencyConfig struct {
+	keyFunc      KeyFunc
+	queueSize    int
+	queueTimeout time.Duration
+	maxInFlight  int
+	maxLatency   time.Duration
+	window       time.Duration
+	reject       gin.HandlerFunc
+}
+
+// ConcurrencyOption 是 ConcurrencyLimit 和 LoadShedding 中间件的配置项
+type ConcurrencyOption func(*concurrencyConfig)
+
+// WithConcurrencyKey 设置并发计数的键，返回空字符串的请求不受限制。
+// ConcurrencyLimit 默认为 KeyByPath，即每个路由单独计数；LoadShedding 默认所有请求共用一个计数
+func WithConcurrencyKey(keyFunc KeyFunc) ConcurrencyOption {
+	return func(c *concurrencyConfig) {
+		c.keyFunc = keyFunc
+	}
+}
+
+// WithQueue 允许最多 size 个请求排队等待，等待超过 timeout 的请求被拒绝
+func WithQueue(size int, timeout time.Duration) ConcurrencyOption {
+	return func(c *concurrencyConfig) {
+		c.queueSize = size
+		c.queueTimeout = timeout
+	}
+}
+
+// WithMaxInFlight 设置 LoadShedding 允许的最大处理中请求数，小于等于 0 时不限制
+func WithMaxInFlight(n int) ConcurrencyOption {
+	return func(c *concurrencyConfig) {
+		c.maxInFlight = n
+	}
+}
+
+// WithMaxLatency 设置 LoadShedding 允许的 p99 延迟，最近 window 内的 p99 超过 latency 时拒绝新请求，
+// window 为 0 时使用 10 秒
+func WithMaxLatency(latency, window time.Duration) ConcurrencyOption {
+	return func(c *concurrencyConfig) {
+		c.maxLatency = latency
+		if window > 0 {
+			c.window = window
+		}
+	}
+}
+
+// WithOverloadHandler 设置请求被拒绝时的响应，默认返回 503
+func WithOverloadHandler(handler gin.HandlerFunc) ConcurrencyOption {
+	return func(c *concurrencyConfig) {
+		c.reject = handler
+	}
+}
+
+// defaultOverload 是默认的过载响应
+func defaultOverload(c *gin.Context) {
+	c.Header("Retry-After", "1")
+	c.JSON(http.StatusServiceUnavailable, gin.H{
+		"message": "Service is busy, please try again later.",
+	})
+}
+
+func newConcurrencyConfig(keyFunc KeyFunc, opts []ConcurrencyOption) *concurrencyConfig {
+	cfg := &concurrencyConfig{
+		keyFunc: keyFunc,
+		window:  10 * time.Second,
+		reject:  defaultOverload,
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return cfg
+}
+
+// semaphore 是一个键的并发计数，refs 为零时从 map 中删除
+type semaphore struct {
+	slots   chan struct{}
+	waiting int
+	refs    int
+}
+
+// ConcurrencyLimit 是一个 Gin 中间件，限制每个键同时处理的请求数不超过 max，
+// 超出的请求按 WithQueue 排队，队列已满或等待超时时拒绝，例如：
+//
+//	router.GET("/report", middleware.ConcurrencyLimit(4, middleware.WithQueue(16, 2*time.Second)), handler)
+func ConcurrencyLimit(max int, opts ...ConcurrencyOption) gin.HandlerFunc {
+	if max <= 0 {
+		panic("middleware: concurrency limit must be positive")
+	}
+	cfg := newConcurrencyConfig(KeyByPath(), opts)
+	var mu sync.Mutex
+	sems := make(map[string]*semaphore)
+
+	release := func(key string, sem *semaphore, acquired bool) {
+		if acquired {
+			<-sem.slots
+		}
+		mu.Lock()
+		sem.refs--
+		if sem.refs == 0 {
+			delete(sems, key)
+		}
+		mu.Unlock()
+	}
+
+	return func(c *gin.Context) {
+		key := cfg.keyFunc(c)
+		if key == "" {
+			c.Next()
+			return
+		}
+
+		mu.Lock()
+		sem, ok := sems[key]
+		if !ok {
+			sem = &semaphore{slots: make(chan struct{}, max)}
+			sems[key] = sem
+		}
+		sem.refs++
+		mu.Unlock()
+
+		select {
+		case sem.slots <- struct{}{}:
+		default:
+			if !wait(c, &mu, sem, cfg) {
+				release(key, sem, false)
+				cfg.reject(c)
+				c.Abort()
+				return
+			}
+		}
+		defer release(key, sem, true)
+		c.Next()
+	}
+}
+
+// wait 在队列未满时排队等待空位，返回是否拿到了空位
+func wait(c *gin.Context, mu *sync.Mutex, sem *semaphore, cfg *concurrencyConfig) bool {
+	mu.Lock()
+	if sem.waiting >= cfg.queueSize {
+		mu.Unlock()
+		return false
+	}
+	sem.waiting++
+	mu.Unlock()
+	defer func() {
+		mu.Lock()
+		sem.waiting--
+		mu.Unlock()
+	}()
+
+	var timeout <-chan time.Time
+	if cfg.queueTimeout > 0 {
+		timer := time.NewTimer(cfg.queueTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case sem.slots <- struct{}{}:
+		return true
+	case <-timeout:
+		return false
+	case <-c.Request.Context().Done():
+		return false
+	}
+}
+
+// maxLatencySamples 是 LoadShedding 保留的延迟样本数上限
+const maxLatencySamples = 1024
+
+// latencyWindow 记录最近一段时间内的请求延迟
+type latencyWindow struct {
+	mu      sync.Mutex
+	window  time.Duration
+	samples []latencySample
+	next    int
+	p99     time.Duration
+	updated time.Time
+}
+
+type latencySample struct {
+	at      time.Time
+	latency time.Duration
+}
+
+// add 记录一个样本，样本数达到上限后覆盖最早的样本
+func (w *latencyWindow) add(now time.Time, latency time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	sample := latencySample{at: now, latency: latency}
+	if len(w.samples) < maxLatencySamples {
+		w.samples = append(w.samples, sample)
+		return
+	}
+	w.samples[w.next] = sample
+	w.next = (w.next + 1) % maxLatencySamples
+}
+
+// percentile99 返回窗口内样本的 p99，结果缓存窗口的十分之一（最多一秒）以免每个请求都排序
+func (w *latencyWindow) percentile99(now time.Time) time.Duration {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if now.Sub(w.updated) < min(w.window/10, time.Second) {
+		return w.p99
+	}
+	latencies := make([]time.Duration, 0, len(w.samples))
+	for _, s := range w.samples {
+		if now.Sub(s.at) <= w.window {
+			latencies = append(latencies, s.latency)
+		}
+	}
+	w.updated = now
+	w.p99 = 0
+	if len(latencies) > 0 {
+		sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+		w.p99 = latencies[(len(latencies)*99-1)/100]
+	}
+	return w.p99
+}
+
+// shedState 是 LoadShedding 中一个键的处理中请求数和延迟样本
+type shedState struct {
+	inFlight  int
+	latencies *latencyWindow
+	lastSeen  time.Time
+}
+
+// globalShedKey 是 LoadShedding 默认使用的键，所有请求共用一个计数
+func globalShedKey(*gin.Context) string {
+	return "*"
+}
+
+// LoadShedding 是一个 Gin 中间件，在处理中的请求数超过 WithMaxInFlight，
+// 或最近的 p99 延迟超过 WithMaxLatency 时直接拒绝新请求，用于保护慢速的下游服务。
+// 拒绝期间没有新的延迟样本，窗口内的样本过期后自动恢复放行。
+// 默认所有请求共用一个计数，WithConcurrencyKey 可以改为按路由或其他键分别计数，
+// 例如 LoadShedding(WithConcurrencyKey(KeyByPath()), WithMaxLatency(time.Second, 0))
+func LoadShedding(opts ...ConcurrencyOption) gin.HandlerFunc {
+	cfg := newConcurrencyConfig(globalShedKey, opts)
+	var mu sync.Mutex
+	states := make(map[string]*shedState)
+	var lastSweep time.Time
+
+	// sweep 删除窗口内没有请求的键，调用方需要持有锁
+	sweep := func(now time.Time) {
+		lastSweep = now
+		for key, state := range states {
+			if state.inFlight == 0 && now.Sub(state.lastSeen) > cfg.window {
+				delete(states, key)
+			}
+		}
+	}
+
+	return func(c *gin.Context) {
+		key := cfg.keyFunc(c)
+		if key == "" {
+			c.Next()
+			return
+		}
+
+		start := time.Now()
+		mu.Lock()
+		state, ok := states[key]
+		if !ok {
+			if start.Sub(lastSweep) >= cfg.window {
+				sweep(start)
+			}
+			state = &shedState{latencies: &latencyWindow{window: cfg.window}}
+			states[key] = state
+		}
+		state.inFlight++
+		state.lastSeen = start
+		n := state.inFlight
+		mu.Unlock()
+		defer func() {
+			mu.Lock()
+			state.inFlight--
+			state.lastSeen = time.Now()
+			mu.Unlock()
+		}()
+
+		overloaded := cfg.maxInFlight > 0 && n > cfg.maxInFlight
+		if !overloaded && cfg.maxLatency > 0 {
+			overloaded = state.latencies.percentile99(start) > cfg.maxLatency
+		}
+		if overloaded {
+			cfg.reject(c)
+			c.Abort()
+			return
+		}
+
+		c.Next()
+		if cfg.maxLatency > 0 {
+			state.latencies.add(time.Now(), time.Since(start))
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestConcurrencyLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	release := make(chan struct{})
+	started := make(chan struct{}, 10)
+	router := gin.New()
+	router.GET("/slow", ConcurrencyLimit(1, WithQueue(1, time.Second)), func(c *gin.Context) {
+		started <- struct{}{}
+		<-release
+		c.Status(http.StatusOK)
+	})
+
+	do := func() int {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/slow", nil))
+		return w.Code
+	}
+
+	var wg sync.WaitGroup
+	codes := make(chan int, 2)
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			codes <- do()
+		}()
+	}
+	<-started
+	// 一个请求在处理，一个在排队，第三个请求队列已满
+	time.Sleep(50 * time.Millisecond)
+	if code := do(); code != http.StatusServiceUnavailable {
+		t.Errorf("Expected request over queue to be rejected, got %d", code)
+	}
+
+	close(release)
+	wg.Wait()
+	close(codes)
+	for code := range codes {
+		if code != http.StatusOK {
+			t.Errorf("Expected queued request to succeed, got %d", code)
+		}
+	}
+}
+
+func TestConcurrencyLimitQueueTimeout(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	release := make(chan struct{})
+	defer close(release)
+	started := make(chan struct{}, 1)
+	router := gin.New()
+	router.GET("/slow", ConcurrencyLimit(1, WithQueue(1, 20*time.Millisecond)), func(c *gin.Context) {
+		started <- struct{}{}
+		<-release
+	})
+
+	go router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/slow", nil))
+	// 等后台请求占用空位之后再发送，否则前台请求会拿到空位并一直阻塞
+	<-started
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/slow", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected queued request to time out, got %d", w.Code)
+	}
+}
+
+func TestLoadShedding(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/api", LoadShedding(WithMaxLatency(10*time.Millisecond, 100*time.Millisecond)), func(c *gin.Context) {
+		if c.Query("slow") != "" {
+			time.Sleep(20 * time.Millisecond)
+		}
+		c.Status(http.StatusOK)
+	})
+
+	do := func(path string) int {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w.Code
+	}
+
+	if code := do("/api?slow=1"); code != http.StatusOK {
+		t.Fatalf("Expected first request to pass, got %d", code)
+	}
+	if code := do("/api"); code != http.StatusServiceUnavailable {
+		t.Errorf("Expected request to be shed after slow responses, got %d", code)
+	}
+	// 样本过期后恢复
+	time.Sleep(150 * time.Millisecond)
+	if code := do("/api"); code != http.StatusOK {
+		t.Errorf("Expected request to pass after the window, got %d", code)
+	}
+}
+
+func TestLoadSheddingByKey(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(LoadShedding(WithConcurrencyKey(KeyByPath()), WithMaxLatency(10*time.Millisecond, 100*time.Millisecond)))
+	router.GET("/slow", func(c *gin.Context) {
+		time.Sleep(20 * time.Millisecond)
+		c.Status(http.StatusOK)
+	})
+	router.GET("/fast", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	do := func(path string) int {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w.Code
+	}
+
+	do("/slow")
+	// 慢速路由被拒绝，其他路由不受影响
+	if code := do("/slow"); code != http.StatusServiceUnavailable {
+		t.Errorf("Expected slow route to be shed, got %d", code)
+	}
+	if code := do("/fast"); code != http.StatusOK {
+		t.Errorf("Expected fast route to pass, got %d", code)
+	}
+}
@@ -0,0 +1,79 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+
+	"github.com/gin-gonic/gin"
+	minutil "github.com/yowaimono/min-util"
+)
+
+// KeyFunc 从请求中提取限流或并发控制的键，返回空字符串时该请求不受限制
+type KeyFunc func(c *gin.Context) string
+
+// generateKey 生成一个唯一的键，基于用户 IP 和 User-Agent
+func generateKey(c *gin.Context) string {
+	ip := c.ClientIP()
+	userAgent := c.GetHeader("User-Agent")
+	hash := sha256.Sum256([]byte(ip + userAgent))
+	return hex.EncodeToString(hash[:])
+}
+
+// KeyByIP 按客户端 IP 限流
+func KeyByIP() KeyFunc {
+	return func(c *gin.Context) string {
+		return c.ClientIP()
+	}
+}
+
+// KeyByIPAndUserAgent 按 IP 和 User-Agent 的组合限流，这是 RateLimitMiddleware 的默认行为。
+// 注意客户端可以通过更换 User-Agent 绕过限流
+func KeyByIPAndUserAgent() KeyFunc {
+	return generateKey
+}
+
+// KeyByUser 按 Auth 保存的用户 ID 限流，未认证的请求使用 fallback，fallback 为 nil 时按 IP
+func KeyByUser(fallback KeyFunc) KeyFunc {
+	if fallback == nil {
+		fallback = KeyByIP()
+	}
+	return func(c *gin.Context) string {
+		if userID := minutil.GetUserID(c); userID != "" {
+			return "user:" + userID
+		}
+		return fallback(c)
+	}
+}
+
+// KeyByHeader 按请求头限流，例如 API Key，头的值以摘要形式出现在键中。
+// 请求头缺失时使用 fallback，fallback 为 nil 时按 IP，省略请求头不能绕过限流
+func KeyByHeader(name string, fallback KeyFunc) KeyFunc {
+	if fallback == nil {
+		fallback = KeyByIP()
+	}
+	return func(c *gin.Context) string {
+		value := c.GetHeader(name)
+		if value == "" {
+			return fallback(c)
+		}
+		return "header:" + minutil.Encrypt(value)
+	}
+}
+
+// KeyByPath 按路由区分，同一路由的全部请求共用一个键
+func KeyByPath() KeyFunc {
+	return func(c *gin.Context) string {
+		return c.Request.Method + " " + c.FullPath()
+	}
+}
+
+// KeyByRoute 在 inner 的基础上区分路由，例如 KeyByRoute(KeyByIP()) 表示每个路由每个 IP 单独计数
+func KeyByRoute(inner KeyFunc) KeyFunc {
+	return func(c *gin.Context) string {
+		key := inner(c)
+		if key == "" {
+			return ""
+		}
+		return c.Request.Method + " " + c.FullPath() + ":" + key
+	}
+}
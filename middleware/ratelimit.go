@@ -0,0 +1,160 @@
+// Package middleware 提供基于 minutil 的 Gin 中间件
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	minutil "github.com/yowaimono/min-util"
+	"github.com/yowaimono/min-util/ratelimit"
+	"github.com/yowaimono/min-util/req"
+)
+
+// HeaderStyle 是限流响应头的格式
+type HeaderStyle int
+
+const (
+	// HeadersXRateLimit 使用 X-RateLimit-Limit、X-RateLimit-Remaining 和 X-RateLimit-Reset（Unix 时间戳，秒）
+	HeadersXRateLimit HeaderStyle = iota
+	// HeadersIETF 使用 IETF 草案的 RateLimit-Limit、RateLimit-Remaining 和 RateLimit-Reset（剩余秒数）
+	HeadersIETF
+	// HeadersNone 不输出配额相关的响应头，被拒绝时仍然输出 Retry-After
+	HeadersNone
+)
+
+// RejectHandler 在请求被限流时写入响应，中间件随后会中止请求
+type RejectHandler func(c *gin.Context, result ratelimit.Result)
+
+// rateLimitConfig 是 RateLimit 中间件的配置
+type rateLimitConfig struct {
+	keyFunc KeyFunc
+	scope   string
+	headers HeaderStyle
+	reject  RejectHandler
+}
+
+// RateLimitOption 是 RateLimit 中间件的配置项
+type RateLimitOption func(*rateLimitConfig)
+
+// WithKeyFunc 设置限流键的提取方式，默认为 KeyByIP
+func WithKeyFunc(keyFunc KeyFunc) RateLimitOption {
+	return func(c *rateLimitConfig) {
+		c.keyFunc = keyFunc
+	}
+}
+
+// WithScope 为键加上命名空间，不同路由使用不同规则时避免共用计数，ratelimit.Metrics 按命名空间分别统计
+func WithScope(scope string) RateLimitOption {
+	return func(c *rateLimitConfig) {
+		c.scope = scope
+	}
+}
+
+// WithHeaders 设置限流响应头的格式，默认为 HeadersXRateLimit
+func WithHeaders(style HeaderStyle) RateLimitOption {
+	return func(c *rateLimitConfig) {
+		c.headers = style
+	}
+}
+
+// WithRejectHandler 设置请求被限流时的响应
+func WithRejectHandler(reject RejectHandler) RateLimitOption {
+	return func(c *rateLimitConfig) {
+		c.reject = reject
+	}
+}
+
+// RejectWithCode 返回一个使用统一响应结构的 RejectHandler，HTTP 状态码为 429
+func RejectWithCode(code req.ErrorCode) RejectHandler {
+	return func(c *gin.Context, result ratelimit.Result) {
+		req.OfStatus(c, http.StatusTooManyRequests, code)
+	}
+}
+
+// defaultReject 是默认的限流响应
+func defaultReject(c *gin.Context, result ratelimit.Result) {
+	c.JSON(http.StatusTooManyRequests, gin.H{
+		"message": "Too many requests, please try again later.",
+	})
+}
+
+// RateLimit 是一个 Gin 中间件，按照 limit 限制请求，例如：
+//
+//	router.POST("/login", middleware.RateLimit(limiter, ratelimit.PerMinute(5), middleware.WithScope("login")))
+func RateLimit(limiter *ratelimit.RateLimiter, limit ratelimit.Limit, opts ...RateLimitOption) gin.HandlerFunc {
+	cfg := &rateLimitConfig{keyFunc: KeyByIP(), reject: defaultReject}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return func(c *gin.Context) {
+		key := cfg.keyFunc(c)
+		if key == "" {
+			c.Next()
+			return
+		}
+		result, err := limiter.TakeScope(cfg.scope, key, limit)
+		if err != nil {
+			// 存储不可用时放行请求，不输出响应头
+			minutil.Error("rate limiter storage error: %v", err)
+			c.Next()
+			return
+		}
+		writeRateLimitHeaders(c, cfg.headers, result)
+		if !result.Allowed {
+			c.Header("Retry-After", strconv.FormatInt(ceilSeconds(result.RetryAfter), 10))
+			cfg.reject(c, result)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// writeRateLimitHeaders 按照格式写入配额相关的响应头
+func writeRateLimitHeaders(c *gin.Context, style HeaderStyle, result ratelimit.Result) {
+	var prefix, reset string
+	switch style {
+	case HeadersXRateLimit:
+		prefix = "X-RateLimit-"
+		reset = strconv.FormatInt(time.Now().Add(result.ResetAfter).Unix(), 10)
+	case HeadersIETF:
+		prefix = "RateLimit-"
+		reset = strconv.FormatInt(ceilSeconds(result.ResetAfter), 10)
+	default:
+		return
+	}
+	c.Header(prefix+"Limit", strconv.Itoa(result.Limit))
+	c.Header(prefix+"Remaining", strconv.Itoa(result.Remaining))
+	c.Header(prefix+"Reset", reset)
+}
+
+// ceilSeconds 把时长向上取整为秒，Retry-After 为 0 会让客户端立即重试
+func ceilSeconds(d time.Duration) int64 {
+	if d <= 0 {
+		return 0
+	}
+	return int64((d + time.Second - 1) / time.Second)
+}
+
+// RateLimitMiddleware 是一个 Gin 中间件，用于限制重复请求，每个 IP 和 User-Agent 在 duration 内只允许一个请求
+func RateLimitMiddleware(limiter *ratelimit.RateLimiter, duration time.Duration, opts ...RateLimitOption) gin.HandlerFunc {
+	opts = append([]RateLimitOption{WithKeyFunc(KeyByIPAndUserAgent())}, opts...)
+	return RateLimit(limiter, ratelimit.Limit{Rate: 1, Period: duration, Burst: 1}, opts...)
+}
+
+// MetricsHandler 返回以 Prometheus 文本格式输出限流指标的 Gin 处理函数，例如：
+//
+//	metrics := ratelimit.NewMetrics()
+//	limiter := ratelimit.NewRateLimiter(storage, ratelimit.WithObserver(metrics))
+//	router.GET("/metrics/ratelimit", middleware.MetricsHandler(metrics))
+func MetricsHandler(metrics *ratelimit.Metrics) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		c.Status(http.StatusOK)
+		if _, err := metrics.WriteTo(c.Writer); err != nil {
+			minutil.Error("failed to write rate limit metrics: %v", err)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	minutil "github.com/yowaimono/min-util"
+	"github.com/yowaimono/min-util/ratelimit"
+	"github.com/yowaimono/min-util/req"
+)
+
+func TestRateLimitKeyFuncs(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tokenManager := minutil.NewJWTTokenManager(minutil.NewKeyRing(minutil.NewHMACKey("k1", []byte("my_secret_key"))), minutil.NewMapTokenStore())
+	limiter := ratelimit.NewRateLimiter(ratelimit.NewMemoryStorage())
+
+	router := gin.New()
+	router.GET("/search", Auth(tokenManager), RateLimit(limiter, ratelimit.PerMinute(1), WithScope("search"), WithKeyFunc(KeyByUser(nil))), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	router.GET("/api", RateLimit(limiter, ratelimit.PerMinute(1), WithKeyFunc(KeyByRoute(KeyByHeader("X-API-Key", nil)))), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	do := func(path string, setup func(r *http.Request)) int {
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		setup(r)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	// 同一用户更换 User-Agent 也无法绕过限流
+	token, err := tokenManager.GenerateToken("123456", "testuser", time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+	withUser := func(ua string) func(r *http.Request) {
+		return func(r *http.Request) {
+			r.Header.Set("Authorization", "Bearer "+token)
+			r.Header.Set("User-Agent", ua)
+		}
+	}
+	if code := do("/search", withUser("a")); code != http.StatusOK {
+		t.Errorf("Expected first request to pass, got %d", code)
+	}
+	if code := do("/search", withUser("b")); code != http.StatusTooManyRequests {
+		t.Errorf("Expected second request of the same user to be limited, got %d", code)
+	}
+
+	// 不同 API Key 各自计数
+	withKey := func(key string) func(r *http.Request) {
+		return func(r *http.Request) { r.Header.Set("X-API-Key", key) }
+	}
+	if code := do("/api", withKey("k1")); code != http.StatusOK {
+		t.Errorf("Expected first request to pass, got %d", code)
+	}
+	if code := do("/api", withKey("k2")); code != http.StatusOK {
+		t.Errorf("Expected request with another API key to pass, got %d", code)
+	}
+	if code := do("/api", withKey("k1")); code != http.StatusTooManyRequests {
+		t.Errorf("Expected second request with the same API key to be limited, got %d", code)
+	}
+	// 缺少请求头时按 IP 限流
+	if code := do("/api", withKey("")); code != http.StatusOK {
+		t.Errorf("Expected first request without API key to pass, got %d", code)
+	}
+	if code := do("/api", withKey("")); code != http.StatusTooManyRequests {
+		t.Errorf("Expected request without API key to be limited by IP, got %d", code)
+	}
+}
+
+func TestRateLimitHeaders(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	limiter := ratelimit.NewRateLimiter(ratelimit.NewMemoryStorage())
+	router := gin.New()
+	router.GET("/x", RateLimit(limiter, ratelimit.PerMinute(2), WithScope("x")), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	router.GET("/ietf", RateLimit(limiter, ratelimit.PerMinute(1), WithScope("ietf"), WithHeaders(HeadersIETF), WithRejectHandler(RejectWithCode(req.ErrTooManyRequests))), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	do := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	w := do("/x")
+	if w.Header().Get("X-RateLimit-Limit") != "2" || w.Header().Get("X-RateLimit-Remaining") != "1" {
+		t.Errorf("Unexpected headers: %v", w.Header())
+	}
+	reset, err := strconv.ParseInt(w.Header().Get("X-RateLimit-Reset"), 10, 64)
+	if err != nil || reset < time.Now().Unix() {
+		t.Errorf("Expected reset to be a future unix timestamp, got %q", w.Header().Get("X-RateLimit-Reset"))
+	}
+
+	if w = do("/ietf"); w.Code != http.StatusOK || w.Header().Get("RateLimit-Remaining") != "0" || w.Header().Get("RateLimit-Reset") != "60" {
+		t.Errorf("Unexpected response: %d %v", w.Code, w.Header())
+	}
+	w = do("/ietf")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "60" {
+		t.Errorf("Expected 429 with Retry-After, got %d %v", w.Code, w.Header())
+	}
+	var body req.Req[string]
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || body.Code != int(req.ErrTooManyRequests) {
+		t.Errorf("Expected envelope body, got %s", w.Body.String())
+	}
+}
+
+func TestMetricsHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	metrics := ratelimit.NewMetrics()
+	limiter := ratelimit.NewRateLimiter(ratelimit.NewMemoryStorage(), ratelimit.WithObserver(metrics))
+	router := gin.New()
+	router.GET("/login", RateLimit(limiter, ratelimit.PerMinute(1), WithScope("login")), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	router.GET("/metrics", MetricsHandler(metrics))
+
+	for i := 0; i < 2; i++ {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/login", nil))
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if !strings.Contains(w.Body.String(), `ratelimit_requests_total{scope="login",result="denied"} 1`) {
+		t.Errorf("Unexpected metrics:\n%s", w.Body.String())
+	}
+}
@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	minutil "github.com/yowaimono/min-util"
+	"github.com/yowaimono/min-util/req"
+)
+
+// RequirePolicy 是一个 Gin 中间件，要求当前用户的角色在策略 p 中拥有全部指定权限，
+// 需要注册在 Auth 之后
+func RequirePolicy(p *minutil.Policy, permissions ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requirePermission(c, p, permissions)
+	}
+}
+
+// RequirePermission 使用 minutil.DefaultPolicy 检查权限，每次请求时读取当前的默认策略
+func RequirePermission(permissions ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requirePermission(c, minutil.DefaultPolicy(), permissions)
+	}
+}
+
+// requirePermission 检查当前用户的角色是否拥有全部权限，没有时中止请求
+func requirePermission(c *gin.Context, p *minutil.Policy, permissions []string) {
+	claims, ok := minutil.GetClaims(c)
+	if !ok {
+		req.Of(c, req.ErrUnauthorized)
+		c.Abort()
+		return
+	}
+	for _, permission := range permissions {
+		if !p.Allowed(claims.Roles, permission) {
+			req.Of(c, req.ErrPermissionDenied)
+			c.Abort()
+			return
+		}
+	}
+	c.Next()
+}
+
+// RequireRole 是一个 Gin 中间件，要求当前用户至少拥有其中一个角色，
+// 需要注册在 Auth 之后
+func RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, ok := minutil.GetClaims(c)
+		if !ok {
+			req.Of(c, req.ErrUnauthorized)
+			c.Abort()
+			return
+		}
+		for _, role := range roles {
+			if claims.HasRole(role) {
+				c.Next()
+				return
+			}
+		}
+		req.Of(c, req.ErrPermissionDenied)
+		c.Abort()
+	}
+}
@@ -0,0 +1,112 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	minutil "github.com/yowaimono/min-util"
+)
+
+// RequestIDHeader 是请求 ID 默认使用的请求头和响应头
+const RequestIDHeader = "X-Request-ID"
+
+// RequestIDKey 是请求 ID 在 gin.Context 中的键
+const RequestIDKey = "request_id"
+
+// maxRequestIDLength 是接受的外部请求 ID 的最大长度
+const maxRequestIDLength = 128
+
+// requestIDConfig 是 RequestID 中间件的配置
+type requestIDConfig struct {
+	header    string
+	generator func() string
+}
+
+// RequestIDOption 是 RequestID 中间件的配置项
+type RequestIDOption func(*requestIDConfig)
+
+// WithRequestIDHeader 设置请求 ID 使用的请求头和响应头，默认为 X-Request-ID
+func WithRequestIDHeader(header string) RequestIDOption {
+	return func(c *requestIDConfig) {
+		c.header = header
+	}
+}
+
+// WithRequestIDGenerator 设置生成请求 ID 的函数，默认为 32 位十六进制随机数
+func WithRequestIDGenerator(generator func() string) RequestIDOption {
+	return func(c *requestIDConfig) {
+		c.generator = generator
+	}
+}
+
+// RequestID 是一个 Gin 中间件，沿用上游传入的请求 ID，没有时生成一个新的，
+// 并写入响应头、gin.Context 和请求的 context，之后可以用 minutil.InfoCtx(c, ...) 记录带请求 ID 的日志。
+// 请求带有 W3C traceparent 头时同时记录 trace ID 和 span ID
+func RequestID(opts ...RequestIDOption) gin.HandlerFunc {
+	cfg := &requestIDConfig{
+		header:    RequestIDHeader,
+		generator: newRequestID,
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return func(c *gin.Context) {
+		id := c.GetHeader(cfg.header)
+		if !validRequestID(id) {
+			id = cfg.generator()
+		}
+		c.Set(RequestIDKey, id)
+		c.Header(cfg.header, id)
+
+		ctx := minutil.ContextWithRequestID(c.Request.Context(), id)
+		if traceID, spanID, ok := parseTraceparent(c.GetHeader("traceparent")); ok {
+			ctx = minutil.ContextWithTrace(ctx, traceID, spanID)
+		}
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
+
+// GetRequestID 返回 RequestID 中间件保存的请求 ID
+func GetRequestID(c *gin.Context) string {
+	return c.GetString(RequestIDKey)
+}
+
+// newRequestID 生成一个随机的请求 ID
+func newRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// validRequestID 检查外部传入的请求 ID，拒绝过长或含有控制字符的值，避免污染日志
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// parseTraceparent 解析 W3C traceparent 头：version-traceid-spanid-flags
+func parseTraceparent(header string) (traceID, spanID string, ok bool) {
+	parts := strings.Split(strings.TrimSpace(header), "-")
+	if len(parts) < 4 || len(parts[1]) != 32 || len(parts[2]) != 16 {
+		return "", "", false
+	}
+	if !isHex(parts[1]) || !isHex(parts[2]) || strings.Trim(parts[1], "0") == "" {
+		return "", "", false
+	}
+	return parts[1], parts[2], true
+}
+
+func isHex(s string) bool {
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
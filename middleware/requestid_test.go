@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	minutil "github.com/yowaimono/min-util"
+)
+
+func TestRequestID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var buf bytes.Buffer
+	logger := minutil.NewLogger(minutil.WithOutput(&buf))
+	router := gin.New()
+	router.GET("/", RequestID(), func(c *gin.Context) {
+		logger.InfoCtx(c, "handled")
+		c.String(http.StatusOK, GetRequestID(c))
+	})
+
+	// 沿用上游的请求 ID 和 trace
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set(RequestIDHeader, "abc-123")
+	r.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if w.Header().Get(RequestIDHeader) != "abc-123" || w.Body.String() != "abc-123" {
+		t.Errorf("Expected request ID to be propagated, got %q %q", w.Header().Get(RequestIDHeader), w.Body.String())
+	}
+	if line := buf.String(); !strings.Contains(line, "request_id=abc-123 trace_id=4bf92f3577b34da6a3ce929d0e0e4736 span_id=00f067aa0ba902b7") {
+		t.Errorf("Expected log line to contain request and trace IDs, got %q", line)
+	}
+
+	// 缺失或不合法时生成新的请求 ID
+	r = httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set(RequestIDHeader, "bad id\n")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if id := w.Header().Get(RequestIDHeader); len(id) != 32 || id != w.Body.String() {
+		t.Errorf("Expected a generated request ID, got %q", id)
+	}
+}
@@ -0,0 +1,205 @@
+package ratelimit
+
+import (
+	"math"
+	"time"
+)
+
+// Limit 描述限流规则：每 Period 允许 Rate 个请求，最多允许突发 Burst 个
+type Limit struct {
+	Rate   int
+	Period time.Duration
+	Burst  int // 为 0 时等于 Rate
+}
+
+// PerSecond 返回每秒 rate 个请求的规则
+func PerSecond(rate int) Limit {
+	return Limit{Rate: rate, Period: time.Second}
+}
+
+// PerMinute 返回每分钟 rate 个请求的规则
+func PerMinute(rate int) Limit {
+	return Limit{Rate: rate, Period: time.Minute}
+}
+
+// PerHour 返回每小时 rate 个请求的规则
+func PerHour(rate int) Limit {
+	return Limit{Rate: rate, Period: time.Hour}
+}
+
+// WithBurst 返回设置了突发容量的规则
+func (l Limit) WithBurst(burst int) Limit {
+	l.Burst = burst
+	return l
+}
+
+// burst 返回突发容量
+func (l Limit) burst() int {
+	if l.Burst > 0 {
+		return l.Burst
+	}
+	return l.Rate
+}
+
+// interval 返回每个请求平均占用的时间
+func (l Limit) interval() time.Duration {
+	return l.Period / time.Duration(l.Rate)
+}
+
+// ttl 返回状态需要保留的时间，超过之后状态等同于初始状态
+func (l Limit) ttl() time.Duration {
+	return l.Period + time.Duration(l.burst())*l.interval()
+}
+
+// State 是限流算法保存在存储中的状态，不同算法使用不同的字段
+type State struct {
+	Tokens    float64     `json:"tokens,omitempty"`     // 令牌桶：剩余令牌
+	Updated   time.Time   `json:"updated,omitempty"`    // 令牌桶：上次补充令牌的时间
+	TAT       time.Time   `json:"tat,omitempty"`        // GCRA：理论到达时间
+	Window    time.Time   `json:"window,omitempty"`     // 滑动窗口计数：当前窗口的起点
+	Count     int         `json:"count,omitempty"`      // 滑动窗口计数：当前窗口的请求数
+	PrevCount int         `json:"prev_count,omitempty"` // 滑动窗口计数：上一个窗口的请求数
+	Log       []time.Time `json:"log,omitempty"`        // 滑动窗口日志：窗口内每个请求的时间
+}
+
+// Result 是一次限流检查的结果
+type Result struct {
+	Allowed    bool
+	Limit      int           // 规则允许的请求数
+	Remaining  int           // 本次检查之后剩余的配额
+	ResetAfter time.Duration // 配额完全恢复还需要的时间
+	RetryAfter time.Duration // 被拒绝时，距离下一个请求可以放行的时间，放行时为 0
+}
+
+// Algorithm 是限流算法，根据状态判断是否放行并就地更新状态
+type Algorithm interface {
+	Take(state *State, limit Limit, now time.Time) Result
+}
+
+var (
+	// TokenBucket 令牌桶：以 Rate/Period 的速度补充令牌，桶容量为 Burst
+	TokenBucket Algorithm = tokenBucket{}
+	// SlidingWindowLog 滑动窗口日志：精确记录最近 Period 内的每个请求，内存占用与 Rate 成正比
+	SlidingWindowLog Algorithm = slidingWindowLog{}
+	// SlidingWindowCounter 滑动窗口计数：用上一个窗口的计数按比例估算，内存占用固定
+	SlidingWindowCounter Algorithm = slidingWindowCounter{}
+	// GCRA 通用信元速率算法：只保存一个理论到达时间，效果等同于令牌桶
+	GCRA Algorithm = gcra{}
+)
+
+type tokenBucket struct{}
+
+func (tokenBucket) Take(state *State, limit Limit, now time.Time) Result {
+	capacity := float64(limit.burst())
+	if state.Updated.IsZero() {
+		state.Tokens = capacity
+	} else if elapsed := now.Sub(state.Updated); elapsed > 0 {
+		state.Tokens += float64(elapsed) / float64(limit.interval())
+		if state.Tokens > capacity {
+			state.Tokens = capacity
+		}
+	}
+	state.Updated = now
+	result := Result{Limit: limit.burst()}
+	if state.Tokens < 1 {
+		result.RetryAfter = tokensDuration(1-state.Tokens, limit)
+	} else {
+		state.Tokens--
+		result.Allowed = true
+	}
+	result.Remaining = int(state.Tokens)
+	result.ResetAfter = tokensDuration(capacity-state.Tokens, limit)
+	return result
+}
+
+type slidingWindowLog struct{}
+
+func (slidingWindowLog) Take(state *State, limit Limit, now time.Time) Result {
+	windowStart := now.Add(-limit.Period)
+	i := 0
+	for i < len(state.Log) && !state.Log[i].After(windowStart) {
+		i++
+	}
+	state.Log = state.Log[i:]
+	result := Result{Limit: limit.Rate}
+	if len(state.Log) >= limit.Rate {
+		// 最早的请求移出窗口后才有配额
+		result.RetryAfter = state.Log[len(state.Log)-limit.Rate].Add(limit.Period).Sub(now)
+	} else {
+		state.Log = append(state.Log, now)
+		result.Allowed = true
+	}
+	result.Remaining = limit.Rate - len(state.Log)
+	if len(state.Log) > 0 {
+		result.ResetAfter = state.Log[len(state.Log)-1].Add(limit.Period).Sub(now)
+	}
+	return result
+}
+
+type slidingWindowCounter struct{}
+
+func (slidingWindowCounter) Take(state *State, limit Limit, now time.Time) Result {
+	window := now.Truncate(limit.Period)
+	if !window.Equal(state.Window) {
+		if window.Sub(state.Window) == limit.Period {
+			state.PrevCount = state.Count
+		} else {
+			state.PrevCount = 0
+		}
+		state.Count = 0
+		state.Window = window
+	}
+	// 上一个窗口的请求按剩余重叠比例计入
+	elapsed := now.Sub(window)
+	overlap := 1 - float64(elapsed)/float64(limit.Period)
+	estimate := float64(state.PrevCount)*overlap + float64(state.Count)
+	result := Result{Limit: limit.Rate}
+	if estimate >= float64(limit.Rate) {
+		// 等待上一个窗口的计数衰减到有配额为止，当前窗口已满时等到下一个窗口
+		free := float64(limit.Rate - state.Count)
+		if free > 0 && state.PrevCount > 0 {
+			result.RetryAfter = time.Duration((1-free/float64(state.PrevCount))*float64(limit.Period)) - elapsed
+		} else {
+			result.RetryAfter = limit.Period - elapsed
+		}
+	} else {
+		state.Count++
+		estimate++
+		result.Allowed = true
+	}
+	result.Remaining = int(math.Max(0, float64(limit.Rate)-math.Ceil(estimate)))
+	result.ResetAfter = limit.Period - elapsed
+	if state.Count > 0 {
+		// 当前窗口的请求要到下一个窗口结束才完全不计入
+		result.ResetAfter += limit.Period
+	}
+	return result
+}
+
+type gcra struct{}
+
+func (gcra) Take(state *State, limit Limit, now time.Time) Result {
+	interval := limit.interval()
+	tolerance := time.Duration(limit.burst()) * interval
+	tat := state.TAT
+	if tat.Before(now) {
+		tat = now
+	}
+	result := Result{Limit: limit.burst()}
+	next := tat.Add(interval)
+	if allowAt := next.Add(-tolerance); now.Before(allowAt) {
+		result.RetryAfter = allowAt.Sub(now)
+	} else {
+		tat = next
+		state.TAT = tat
+		result.Allowed = true
+	}
+	result.Remaining = int((now.Sub(tat) + tolerance) / interval)
+	result.ResetAfter = tat.Sub(now)
+	return result
+}
+
+// tokensDuration 返回补充 n 个令牌需要的时间
+func tokensDuration(n float64, limit Limit) time.Duration {
+	return time.Duration(math.Ceil(n * float64(limit.interval())))
+}
@@ -0,0 +1,132 @@
+// Package ratelimit 提供不依赖 HTTP 框架的限流器，Gin 中间件参见 middleware 包
+package ratelimit
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+// Storage 接口定义了存储后端需要实现的方法
+type Storage interface {
+	// Update 原子地读取 key 的状态，交给 fn 修改后写回，ttl 之后状态可以被丢弃。
+	// 分布式存储可能在冲突时重试，因此 fn 可能被调用多次，不能有副作用
+	Update(key string, ttl time.Duration, fn func(state *State)) error
+}
+
+// scriptedStorage 由可以在存储端原子地执行内置算法的存储实现，例如 RedisStorage。
+// 返回 false 表示不支持该算法，RateLimiter 退回到 Update
+type scriptedStorage interface {
+	take(key string, algorithm Algorithm, limit Limit, now time.Time) (Result, bool, error)
+}
+
+// RateLimiter 是一个限流器，使用可替换的限流算法和自定义的存储后端
+type RateLimiter struct {
+	storage   Storage
+	algorithm Algorithm
+	observer  Observer
+}
+
+// LimiterOption 是 RateLimiter 的配置项
+type LimiterOption func(*RateLimiter)
+
+// WithAlgorithm 设置限流算法，默认为 TokenBucket
+func WithAlgorithm(algorithm Algorithm) LimiterOption {
+	return func(rl *RateLimiter) {
+		rl.algorithm = algorithm
+	}
+}
+
+// NewRateLimiter 创建一个新的 RateLimiter 实例
+func NewRateLimiter(storage Storage, opts ...LimiterOption) *RateLimiter {
+	rl := &RateLimiter{
+		storage:   storage,
+		algorithm: TokenBucket,
+	}
+	for _, opt := range opts {
+		opt(rl)
+	}
+	return rl
+}
+
+// Allow 检查是否允许请求，每个 key 在 duration 内只允许一个请求。
+// 存储出错时放行请求并记录日志
+func (rl *RateLimiter) Allow(key string, duration time.Duration) bool {
+	allowed, err := rl.AllowLimit(key, Limit{Rate: 1, Period: duration, Burst: 1})
+	if err != nil {
+		log.Printf("rate limiter storage error: %v", err)
+		return true
+	}
+	return allowed
+}
+
+// AllowLimit 按照 limit 检查是否允许请求，例如 PerMinute(100).WithBurst(20)
+func (rl *RateLimiter) AllowLimit(key string, limit Limit) (bool, error) {
+	result, err := rl.Take(key, limit)
+	return result.Allowed, err
+}
+
+// Take 按照 limit 检查是否允许请求，并返回剩余配额和恢复时间
+func (rl *RateLimiter) Take(key string, limit Limit) (Result, error) {
+	return rl.TakeScope("", key, limit)
+}
+
+// TakeScope 与 Take 相同，但 key 属于命名空间 scope，不同 scope 的同一个 key 分别计数。
+// scope 会原样交给 Observer，例如 Metrics 按 scope 分别统计
+func (rl *RateLimiter) TakeScope(scope, key string, limit Limit) (Result, error) {
+	if scope != "" {
+		key = scope + ":" + key
+	}
+	if limit.Rate <= 0 || limit.Period <= 0 {
+		return Result{}, errors.New("rate limit must have a positive rate and period")
+	}
+	var result Result
+	var err error
+	start := time.Now()
+	handled := false
+	if s, ok := rl.storage.(scriptedStorage); ok {
+		result, handled, err = s.take(key, rl.algorithm, limit, start)
+	}
+	if !handled {
+		err = rl.storage.Update(key, limit.ttl(), func(state *State) {
+			result = rl.algorithm.Take(state, limit, time.Now())
+		})
+	}
+	if errors.Is(err, ErrStorageConflict) {
+		// 冲突说明同一个 key 正在被大量并发请求，放行会让限流在最需要的时候失效，因此按拒绝处理
+		result = Result{Limit: limit.burst(), RetryAfter: limit.interval()}
+		err = nil
+	}
+	if rl.observer != nil {
+		rl.observer.Observe(Event{Scope: scope, Key: key, Result: result, Latency: time.Since(start), Err: err})
+	}
+	if err != nil {
+		return Result{}, err
+	}
+	return result, nil
+}
+
+// 示例：使用 sync.Map 作为存储后端，每个 key 有独立的锁。
+//
+// Deprecated: SyncMapStorage 从不删除 key，不同 key 的数量没有上限，使用 MemoryStorage 代替
+type SyncMapStorage struct {
+	cache sync.Map
+}
+
+// syncMapEntry 是 SyncMapStorage 中的一个 key
+type syncMapEntry struct {
+	mu    sync.Mutex
+	state State
+}
+
+func (s *SyncMapStorage) Update(key string, ttl time.Duration, fn func(state *State)) error {
+	val, _ := s.cache.LoadOrStore(key, &syncMapEntry{})
+	entry := val.(*syncMapEntry)
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
+	fn(&entry.state)
+	return nil
+}
+
+// 使用 Redis 作为存储后端，参见 RedisStorage
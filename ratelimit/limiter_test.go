@@ -0,0 +1,284 @@
+package ratelimit
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+)
+
+func TestRateLimitAlgorithms(t *testing.T) {
+	limit := PerMinute(60).WithBurst(5)
+	algorithms := map[string]Algorithm{
+		"TokenBucket":          TokenBucket,
+		"SlidingWindowLog":     SlidingWindowLog,
+		"SlidingWindowCounter": SlidingWindowCounter,
+		"GCRA":                 GCRA,
+	}
+	for name, algorithm := range algorithms {
+		t.Run(name, func(t *testing.T) {
+			var state State
+			now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+			allowed := 0
+			for i := 0; i < 100; i++ {
+				if algorithm.Take(&state, limit, now).Allowed {
+					allowed++
+				}
+			}
+			// 令牌桶和 GCRA 允许突发 5 个，滑动窗口在一个窗口内允许 60 个
+			switch algorithm {
+			case TokenBucket, GCRA:
+				if allowed != 5 {
+					t.Errorf("Expected burst of 5, got %d", allowed)
+				}
+			default:
+				if allowed != 60 {
+					t.Errorf("Expected 60 requests per window, got %d", allowed)
+				}
+			}
+
+			// 一分钟之后配额恢复
+			now = now.Add(time.Minute + time.Second)
+			if !algorithm.Take(&state, limit, now).Allowed {
+				t.Errorf("Expected request to be allowed after the period")
+			}
+		})
+	}
+}
+
+func TestRateLimiterAllow(t *testing.T) {
+	limiter := NewRateLimiter(&SyncMapStorage{}, WithAlgorithm(GCRA))
+	if !limiter.Allow("key", time.Hour) {
+		t.Errorf("Expected first request to be allowed")
+	}
+	if limiter.Allow("key", time.Hour) {
+		t.Errorf("Expected second request within duration to be rejected")
+	}
+	if !limiter.Allow("other", time.Hour) {
+		t.Errorf("Expected request with another key to be allowed")
+	}
+
+	limit := PerSecond(10).WithBurst(3)
+	for i := 0; i < 3; i++ {
+		if ok, err := limiter.AllowLimit("burst", limit); err != nil || !ok {
+			t.Fatalf("Expected request %d to be allowed, got %v %v", i, ok, err)
+		}
+	}
+	if ok, _ := limiter.AllowLimit("burst", limit); ok {
+		t.Errorf("Expected request over burst to be rejected")
+	}
+}
+
+func TestRedisStorage(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+
+	// 16 个实例共享同一个 Redis，模拟负载均衡后的多个副本
+	limit := PerHour(100).WithBurst(20)
+	algorithms := map[string]Algorithm{
+		"TokenBucket":          TokenBucket,
+		"SlidingWindowLog":     SlidingWindowLog,
+		"SlidingWindowCounter": SlidingWindowCounter,
+		"GCRA":                 GCRA,
+		"Custom":               customAlgorithm{TokenBucket}, // 不能在 Redis 中执行，使用比较版本写回
+	}
+	for name, algorithm := range algorithms {
+		t.Run(name, func(t *testing.T) {
+			var replicas []*RateLimiter
+			for i := 0; i < 16; i++ {
+				replicas = append(replicas, NewRateLimiter(NewRedisStorage(client, WithRedisRetries(2)), WithAlgorithm(algorithm)))
+			}
+
+			var wg sync.WaitGroup
+			var allowed atomic.Int32
+			for i := 0; i < 800; i++ {
+				wg.Add(1)
+				go func(limiter *RateLimiter) {
+					defer wg.Done()
+					ok, err := limiter.AllowLimit(name, limit)
+					if err != nil {
+						t.Errorf("Failed to take from limiter: %v", err)
+					}
+					if ok {
+						allowed.Add(1)
+					}
+				}(replicas[i%len(replicas)])
+			}
+			wg.Wait()
+
+			// 版本冲突按拒绝处理，因此放行的数量不会超过配额
+			want := int32(limit.burst())
+			if algorithm == SlidingWindowLog || algorithm == SlidingWindowCounter {
+				want = int32(limit.Rate)
+			}
+			if n := allowed.Load(); n > want || (n != want && name != "Custom") {
+				t.Errorf("Expected %d requests to be allowed across replicas, got %d", want, n)
+			}
+			for _, key := range mr.Keys() {
+				if ttl := mr.TTL(key); ttl <= 0 {
+					t.Errorf("Expected %s to expire, got ttl %v", key, ttl)
+				}
+			}
+		})
+	}
+}
+
+// customAlgorithm 包装内置算法，使 RedisStorage 无法识别
+type customAlgorithm struct {
+	Algorithm
+}
+
+func TestRedisStorageScripts(t *testing.T) {
+	mr := miniredis.RunT(t)
+	storage := NewRedisStorage(redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+	limit := PerMinute(60).WithBurst(5)
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	algorithms := map[string]Algorithm{
+		"TokenBucket":          TokenBucket,
+		"SlidingWindowLog":     SlidingWindowLog,
+		"SlidingWindowCounter": SlidingWindowCounter,
+		"GCRA":                 GCRA,
+	}
+	for name, algorithm := range algorithms {
+		t.Run(name, func(t *testing.T) {
+			// Lua 脚本的结果与本地执行的算法一致
+			var state State
+			for i, offset := range []time.Duration{0, 0, 0, 0, 0, 0, 0, 500 * time.Millisecond, time.Second, 30 * time.Second, 61 * time.Second, 61 * time.Second} {
+				now := start.Add(offset)
+				want := algorithm.Take(&state, limit, now)
+				got, ok, err := storage.take(name, algorithm, limit, now)
+				if !ok || err != nil {
+					t.Fatalf("Failed to run script: %v %v", ok, err)
+				}
+				if got != want {
+					t.Errorf("Request %d: expected %+v, got %+v", i, want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestRateLimitResult(t *testing.T) {
+	limit := PerMinute(60).WithBurst(5)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for name, algorithm := range map[string]Algorithm{"TokenBucket": TokenBucket, "GCRA": GCRA} {
+		t.Run(name, func(t *testing.T) {
+			var state State
+			for i := 4; i >= 0; i-- {
+				result := algorithm.Take(&state, limit, now)
+				if !result.Allowed || result.Remaining != i {
+					t.Fatalf("Expected remaining %d, got %+v", i, result)
+				}
+			}
+			result := algorithm.Take(&state, limit, now)
+			if result.Allowed || result.Remaining != 0 {
+				t.Fatalf("Expected request to be denied, got %+v", result)
+			}
+			// 每秒恢复一个配额
+			if result.RetryAfter != time.Second || result.ResetAfter != 5*time.Second {
+				t.Errorf("Expected retry after 1s and reset after 5s, got %+v", result)
+			}
+			if !algorithm.Take(&state, limit, now.Add(result.RetryAfter)).Allowed {
+				t.Errorf("Expected request to be allowed after RetryAfter")
+			}
+		})
+	}
+
+	var state State
+	limit = PerMinute(2)
+	SlidingWindowLog.Take(&state, limit, now)
+	SlidingWindowLog.Take(&state, limit, now.Add(10*time.Second))
+	result := SlidingWindowLog.Take(&state, limit, now.Add(20*time.Second))
+	if result.Allowed || result.RetryAfter != 40*time.Second || result.ResetAfter != 50*time.Second {
+		t.Errorf("Unexpected sliding window log result: %+v", result)
+	}
+}
+
+func TestMemoryStorage(t *testing.T) {
+	storage := NewMemoryStorage(WithMaxKeys(2), WithSweepInterval(0))
+	defer storage.Close()
+	limiter := NewRateLimiter(storage)
+
+	limiter.Allow("a", time.Hour)
+	limiter.Allow("b", time.Hour)
+	limiter.Allow("a", time.Hour) // a 成为最近访问的 key
+	limiter.Allow("c", time.Hour) // 淘汰 b
+
+	stats := storage.Stats()
+	if stats.Keys != 2 || stats.Evictions != 1 || stats.Hits != 1 || stats.Misses != 3 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+	if limiter.Allow("a", time.Hour) {
+		t.Errorf("Expected a to be kept")
+	}
+	if !limiter.Allow("b", time.Hour) {
+		t.Errorf("Expected b to be evicted")
+	}
+
+	// 过期的 key 被清理
+	limiter.Allow("short", 10*time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	storage.Sweep()
+	if stats := storage.Stats(); stats.Keys != 1 || stats.Expired != 1 {
+		t.Errorf("Expected expired key to be swept, got %+v", stats)
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	metrics := NewMetrics(WithTopKeys(1))
+	limiter := NewRateLimiter(NewMemoryStorage(WithSweepInterval(0)), WithObserver(metrics))
+	limit := Limit{Rate: 1, Period: time.Hour, Burst: 1}
+	for i := 0; i < 3; i++ {
+		limiter.TakeScope("login", "1.2.3.4", limit)
+		limiter.TakeScope("search", "5.6.7.8", limit)
+	}
+	limiter.TakeScope("search", "5.6.7.8", limit)
+	// 没有命名空间的 key 不会被拆出标签，例如 IPv6 地址
+	limiter.Allow("2001:db8::1", time.Hour)
+
+	if allowed, denied := metrics.Totals(); allowed != 3 || denied != 5 {
+		t.Errorf("Expected 3 allowed and 5 denied, got %d and %d", allowed, denied)
+	}
+	if top := metrics.TopThrottled(1); len(top) != 1 || top[0].Key != "search:5.6.7.8" || top[0].Count != 3 {
+		t.Errorf("Unexpected top throttled keys: %+v", top)
+	}
+
+	var b strings.Builder
+	if _, err := metrics.WriteTo(&b); err != nil {
+		t.Fatalf("Failed to write metrics: %v", err)
+	}
+	for _, line := range []string{
+		`ratelimit_requests_total{scope="login",result="allowed"} 1`,
+		`ratelimit_requests_total{scope="search",result="denied"} 3`,
+		`ratelimit_requests_total{scope="",result="allowed"} 1`,
+		`ratelimit_storage_latency_seconds_count 8`,
+		`ratelimit_throttled_key_denied{key="search:5.6.7.8"} 3`,
+	} {
+		if !strings.Contains(b.String(), line+"\n") {
+			t.Errorf("Expected metrics to contain %q, got:\n%s", line, b.String())
+		}
+	}
+}
+
+func TestMetricsTopKeysBounded(t *testing.T) {
+	metrics := NewMetrics(WithTopKeys(1))
+	denied := Event{Result: Result{Allowed: false}}
+	// 扫描器使用大量随机 key，频繁被拒绝的 key 仍然排在最前面
+	for i := 0; i < 5000; i++ {
+		denied.Key = "scanner:" + strconv.Itoa(i)
+		metrics.Observe(denied)
+		if i%2 == 0 {
+			denied.Key = "abuser"
+			metrics.Observe(denied)
+		}
+	}
+	if top := metrics.TopThrottled(maxTrackedKeys + 1); len(top) != maxTrackedKeys || top[0].Key != "abuser" || top[0].Count < 2500 {
+		t.Errorf("Expected %d tracked keys led by abuser, got %d led by %+v", maxTrackedKeys, len(top), top[0])
+	}
+}
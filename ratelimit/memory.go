@@ -0,0 +1,172 @@
+package ratelimit
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// DefaultMemorySweepInterval 是 MemoryStorage 清理过期 key 的默认间隔
+const DefaultMemorySweepInterval = time.Minute
+
+// DefaultMaxKeys 是 MemoryStorage 默认最多保存的 key 数量
+const DefaultMaxKeys = 100000
+
+// MemoryStorage 是有容量上限的内存存储，过期的 key 由后台协程定期清理，
+// 超过容量时淘汰最久未访问的 key
+type MemoryStorage struct {
+	mu      sync.Mutex
+	items   map[string]*list.Element
+	lru     *list.List // 按访问顺序排列，最前面的最久未访问
+	maxKeys int
+	stats   MemoryStorageStats
+	stop    chan struct{}
+	once    sync.Once
+}
+
+// memoryEntry 是 MemoryStorage 中的一个 key
+type memoryEntry struct {
+	key       string
+	state     State
+	expiresAt time.Time // 零值表示永不过期
+}
+
+// expiredAt 检查 key 在 now 时是否已经过期
+func (e *memoryEntry) expiredAt(now time.Time) bool {
+	return !e.expiresAt.IsZero() && !now.Before(e.expiresAt)
+}
+
+// MemoryStorageStats 是 MemoryStorage 的运行统计
+type MemoryStorageStats struct {
+	Keys      int    // 当前保存的 key 数量
+	Hits      uint64 // 命中已有状态的次数
+	Misses    uint64 // 新建状态的次数
+	Evictions uint64 // 因超过容量被淘汰的 key 数量
+	Expired   uint64 // 因过期被删除的 key 数量
+}
+
+// MemoryStorageOption 是 MemoryStorage 的配置项
+type MemoryStorageOption func(*memoryStorageConfig)
+
+type memoryStorageConfig struct {
+	maxKeys       int
+	sweepInterval time.Duration
+}
+
+// WithMaxKeys 设置最多保存的 key 数量，小于等于 0 时不限制
+func WithMaxKeys(n int) MemoryStorageOption {
+	return func(c *memoryStorageConfig) {
+		c.maxKeys = n
+	}
+}
+
+// WithSweepInterval 设置清理过期 key 的间隔，小于等于 0 时不启动后台清理
+func WithSweepInterval(interval time.Duration) MemoryStorageOption {
+	return func(c *memoryStorageConfig) {
+		c.sweepInterval = interval
+	}
+}
+
+// NewMemoryStorage 创建一个新的 MemoryStorage，不再使用时调用 Close 停止后台清理
+func NewMemoryStorage(opts ...MemoryStorageOption) *MemoryStorage {
+	cfg := memoryStorageConfig{maxKeys: DefaultMaxKeys, sweepInterval: DefaultMemorySweepInterval}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	s := &MemoryStorage{
+		items:   make(map[string]*list.Element),
+		lru:     list.New(),
+		maxKeys: cfg.maxKeys,
+		stop:    make(chan struct{}),
+	}
+	if cfg.sweepInterval > 0 {
+		go s.sweepLoop(cfg.sweepInterval)
+	}
+	return s
+}
+
+// Update 读取并修改 key 的状态，ttl 内没有再次访问的 key 会被删除，ttl 小于等于 0 时不过期
+func (s *MemoryStorage) Update(key string, ttl time.Duration, fn func(state *State)) error {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	elem, ok := s.items[key]
+	if ok && elem.Value.(*memoryEntry).expiredAt(now) {
+		s.remove(elem)
+		s.stats.Expired++
+		ok = false
+	}
+	if ok {
+		s.stats.Hits++
+		s.lru.MoveToBack(elem)
+	} else {
+		s.stats.Misses++
+		if s.maxKeys > 0 {
+			for s.lru.Len() >= s.maxKeys {
+				s.remove(s.lru.Front())
+				s.stats.Evictions++
+			}
+		}
+		elem = s.lru.PushBack(&memoryEntry{key: key})
+		s.items[key] = elem
+	}
+	e := elem.Value.(*memoryEntry)
+	fn(&e.state)
+	e.expiresAt = time.Time{}
+	if ttl > 0 {
+		e.expiresAt = now.Add(ttl)
+	}
+	return nil
+}
+
+// Stats 返回当前的运行统计
+func (s *MemoryStorage) Stats() MemoryStorageStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stats := s.stats
+	stats.Keys = s.lru.Len()
+	return stats
+}
+
+// Sweep 立即删除所有过期的 key
+func (s *MemoryStorage) Sweep() {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for elem := s.lru.Front(); elem != nil; {
+		next := elem.Next()
+		if elem.Value.(*memoryEntry).expiredAt(now) {
+			s.remove(elem)
+			s.stats.Expired++
+		}
+		elem = next
+	}
+}
+
+// Close 停止后台清理协程
+func (s *MemoryStorage) Close() error {
+	s.once.Do(func() {
+		close(s.stop)
+	})
+	return nil
+}
+
+func (s *MemoryStorage) sweepLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.Sweep()
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// remove 删除一个 key，调用方需要持有锁
+func (s *MemoryStorage) remove(elem *list.Element) {
+	s.lru.Remove(elem)
+	delete(s.items, elem.Value.(*memoryEntry).key)
+}
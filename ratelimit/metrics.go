@@ -0,0 +1,262 @@
+package ratelimit
+
+import (
+	"container/heap"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Event 描述一次限流检查，交给 Observer 记录
+type Event struct {
+	Scope   string // TakeScope 的命名空间，没有时为空
+	Key     string // 包含命名空间的完整 key
+	Result  Result
+	Latency time.Duration // 存储读写耗时
+	Err     error
+}
+
+// Observer 接收每次限流检查的结果，实现需要是并发安全的，并且不能阻塞
+type Observer interface {
+	Observe(event Event)
+}
+
+// WithObserver 设置限流检查的观察者，例如 Metrics
+func WithObserver(observer Observer) LimiterOption {
+	return func(rl *RateLimiter) {
+		rl.observer = observer
+	}
+}
+
+// DefaultTopKeys 是 Metrics 默认导出的被拒绝最多的 key 的数量
+const DefaultTopKeys = 10
+
+// maxTrackedKeys 是 Metrics 统计被拒绝次数时最多跟踪的 key 数量
+const maxTrackedKeys = 1000
+
+// latencyBuckets 是存储耗时直方图的桶上限（秒）
+var latencyBuckets = []float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1}
+
+// labelEscaper 按 Prometheus 文本格式转义标签值
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// Metrics 是内置的 Observer，按 scope 统计放行和拒绝次数，并统计被拒绝最多的 key 和存储耗时，
+// 可以通过 WriteTo 导出为 Prometheus 文本格式
+type Metrics struct {
+	topKeys int
+
+	errors  atomic.Uint64
+	buckets []atomic.Uint64 // 最后一个桶是 +Inf
+	sumNs   atomic.Int64
+
+	mu     sync.Mutex
+	counts map[string]*labelCounts // 键为 Event.Scope
+
+	topMu     sync.Mutex
+	throttled map[string]*throttledKey
+	minHeap   throttledHeap // 与 throttled 中的 key 相同，堆顶是拒绝次数最少的 key
+}
+
+// throttledKey 是一个被跟踪的 key 及其拒绝次数
+type throttledKey struct {
+	key   string
+	count uint64
+	index int // 在 throttledHeap 中的位置
+}
+
+// throttledHeap 是按拒绝次数排列的最小堆，实现 heap.Interface
+type throttledHeap []*throttledKey
+
+func (h throttledHeap) Len() int           { return len(h) }
+func (h throttledHeap) Less(i, j int) bool { return h[i].count < h[j].count }
+func (h throttledHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *throttledHeap) Push(x any) {
+	k := x.(*throttledKey)
+	k.index = len(*h)
+	*h = append(*h, k)
+}
+
+func (h *throttledHeap) Pop() any {
+	old := *h
+	k := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	return k
+}
+
+// labelCounts 是一个标签下的放行和拒绝次数
+type labelCounts struct {
+	allowed uint64
+	denied  uint64
+}
+
+// MetricsOption 是 Metrics 的配置项
+type MetricsOption func(*Metrics)
+
+// WithTopKeys 设置导出的被拒绝最多的 key 的数量，为 0 时不导出
+func WithTopKeys(n int) MetricsOption {
+	return func(m *Metrics) {
+		m.topKeys = n
+	}
+}
+
+// NewMetrics 创建一个新的 Metrics
+func NewMetrics(opts ...MetricsOption) *Metrics {
+	m := &Metrics{
+		topKeys:   DefaultTopKeys,
+		buckets:   make([]atomic.Uint64, len(latencyBuckets)+1),
+		counts:    make(map[string]*labelCounts),
+		throttled: make(map[string]*throttledKey),
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// Observe 记录一次限流检查
+func (m *Metrics) Observe(event Event) {
+	seconds := event.Latency.Seconds()
+	i := sort.SearchFloat64s(latencyBuckets, seconds)
+	m.buckets[i].Add(1)
+	m.sumNs.Add(int64(event.Latency))
+	if event.Err != nil {
+		m.errors.Add(1)
+		return
+	}
+
+	m.mu.Lock()
+	counts, ok := m.counts[event.Scope]
+	if !ok {
+		counts = &labelCounts{}
+		m.counts[event.Scope] = counts
+	}
+	if event.Result.Allowed {
+		counts.allowed++
+	} else {
+		counts.denied++
+	}
+	m.mu.Unlock()
+
+	if !event.Result.Allowed && m.topKeys > 0 {
+		m.countThrottled(event.Key)
+	}
+}
+
+// countThrottled 累加 key 的拒绝次数。跟踪的 key 达到上限时替换堆顶次数最少的 key，
+// 新 key 继承它的次数（Space-Saving 算法），频繁被拒绝的 key 不会被低频的 key 挤出。
+// 每次更新的开销是 O(log n)，大量随机 key 不会拖慢拒绝路径
+func (m *Metrics) countThrottled(key string) {
+	m.topMu.Lock()
+	defer m.topMu.Unlock()
+	if k, ok := m.throttled[key]; ok {
+		k.count++
+		heap.Fix(&m.minHeap, k.index)
+		return
+	}
+	if len(m.minHeap) < maxTrackedKeys {
+		k := &throttledKey{key: key, count: 1}
+		m.throttled[key] = k
+		heap.Push(&m.minHeap, k)
+		return
+	}
+	k := m.minHeap[0]
+	delete(m.throttled, k.key)
+	k.key = key
+	k.count++
+	m.throttled[key] = k
+	heap.Fix(&m.minHeap, 0)
+}
+
+// Totals 返回全部标签的放行和拒绝次数之和
+func (m *Metrics) Totals() (allowed, denied uint64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, counts := range m.counts {
+		allowed += counts.allowed
+		denied += counts.denied
+	}
+	return allowed, denied
+}
+
+// KeyCount 是一个 key 及其被拒绝的次数
+type KeyCount struct {
+	Key   string
+	Count uint64
+}
+
+// TopThrottled 返回被拒绝次数最多的 n 个 key，按次数从大到小排列
+func (m *Metrics) TopThrottled(n int) []KeyCount {
+	m.topMu.Lock()
+	top := make([]KeyCount, 0, len(m.minHeap))
+	for _, k := range m.minHeap {
+		top = append(top, KeyCount{Key: k.key, Count: k.count})
+	}
+	m.topMu.Unlock()
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].Count != top[j].Count {
+			return top[i].Count > top[j].Count
+		}
+		return top[i].Key < top[j].Key
+	})
+	if len(top) > n {
+		top = top[:n]
+	}
+	return top
+}
+
+// WriteTo 以 Prometheus 文本格式输出全部指标
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	var b strings.Builder
+
+	m.mu.Lock()
+	labels := make([]string, 0, len(m.counts))
+	for label := range m.counts {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+	b.WriteString("# HELP ratelimit_requests_total Rate limit checks by result.\n")
+	b.WriteString("# TYPE ratelimit_requests_total counter\n")
+	for _, label := range labels {
+		counts := m.counts[label]
+		fmt.Fprintf(&b, "ratelimit_requests_total{scope=\"%s\",result=\"allowed\"} %d\n", labelEscaper.Replace(label), counts.allowed)
+		fmt.Fprintf(&b, "ratelimit_requests_total{scope=\"%s\",result=\"denied\"} %d\n", labelEscaper.Replace(label), counts.denied)
+	}
+	m.mu.Unlock()
+
+	b.WriteString("# HELP ratelimit_storage_errors_total Rate limit checks that failed because of storage errors.\n")
+	b.WriteString("# TYPE ratelimit_storage_errors_total counter\n")
+	fmt.Fprintf(&b, "ratelimit_storage_errors_total %d\n", m.errors.Load())
+
+	b.WriteString("# HELP ratelimit_storage_latency_seconds Time spent reading and writing rate limit state.\n")
+	b.WriteString("# TYPE ratelimit_storage_latency_seconds histogram\n")
+	var cumulative uint64
+	for i, le := range latencyBuckets {
+		cumulative += m.buckets[i].Load()
+		fmt.Fprintf(&b, "ratelimit_storage_latency_seconds_bucket{le=\"%g\"} %d\n", le, cumulative)
+	}
+	cumulative += m.buckets[len(latencyBuckets)].Load()
+	fmt.Fprintf(&b, "ratelimit_storage_latency_seconds_bucket{le=\"+Inf\"} %d\n", cumulative)
+	fmt.Fprintf(&b, "ratelimit_storage_latency_seconds_sum %g\n", time.Duration(m.sumNs.Load()).Seconds())
+	fmt.Fprintf(&b, "ratelimit_storage_latency_seconds_count %d\n", cumulative)
+
+	if m.topKeys > 0 {
+		b.WriteString("# HELP ratelimit_throttled_key_denied Denied checks of the most throttled keys.\n")
+		b.WriteString("# TYPE ratelimit_throttled_key_denied gauge\n")
+		for _, kc := range m.TopThrottled(m.topKeys) {
+			fmt.Fprintf(&b, "ratelimit_throttled_key_denied{key=\"%s\"} %d\n", labelEscaper.Replace(kc.Key), kc.Count)
+		}
+	}
+
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
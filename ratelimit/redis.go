@@ -0,0 +1,286 @@
+package ratelimit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"hash/fnv"
+	"math/rand"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// ErrStorageConflict 表示多次重试后仍然无法写入限流状态
+var ErrStorageConflict = errors.New("rate limit state update conflict")
+
+// DefaultRedisStorageRetries 是 RedisStorage 在版本冲突时的默认重试次数
+const DefaultRedisStorageRetries = 20
+
+// compareAndSetScript 只有在版本号未变化时才写入新状态，并递增版本号和刷新过期时间。
+// KEYS[1] 为状态 key，ARGV 依次为读取时的版本号、新状态和过期毫秒数
+var compareAndSetScript = redis.NewScript(`
+local current = redis.call('HGET', KEYS[1], 'v') or '0'
+if current ~= ARGV[1] then
+	return 0
+end
+redis.call('HSET', KEYS[1], 'v', tonumber(current) + 1, 's', ARGV[2])
+if tonumber(ARGV[3]) > 0 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[3])
+end
+return 1
+`)
+
+// 内置算法的 Lua 脚本，整个检查和更新在 Redis 中原子执行。
+// KEYS[1] 为状态 key，ARGV 依次为当前时间（微秒）、Period（微秒）、Rate、Burst、过期毫秒数和本次请求的唯一标识，
+// 返回 {是否放行, Limit, Remaining, ResetAfter（微秒）, RetryAfter（微秒）}。
+// 时间戳超过 Lua 默认的数字格式精度，写入时需要用 %d 格式化
+var (
+	tokenBucketScript = redis.NewScript(`
+local now, period, rate, burst = tonumber(ARGV[1]), tonumber(ARGV[2]), tonumber(ARGV[3]), tonumber(ARGV[4])
+local interval = period / rate
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'updated')
+local tokens, updated = tonumber(state[1]), tonumber(state[2])
+if not updated then
+	tokens = burst
+elseif now > updated then
+	tokens = math.min(burst, tokens + (now - updated) / interval)
+end
+local allowed, retry = 0, 0
+if tokens < 1 then
+	retry = math.ceil((1 - tokens) * interval)
+else
+	tokens = tokens - 1
+	allowed = 1
+end
+redis.call('HSET', KEYS[1], 'tokens', string.format('%.17g', tokens), 'updated', ARGV[1])
+redis.call('PEXPIRE', KEYS[1], ARGV[5])
+return {allowed, burst, math.floor(tokens), math.ceil((burst - tokens) * interval), retry}
+`)
+
+	gcraScript = redis.NewScript(`
+local now, period, rate, burst = tonumber(ARGV[1]), tonumber(ARGV[2]), tonumber(ARGV[3]), tonumber(ARGV[4])
+local interval = period / rate
+local tolerance = burst * interval
+local tat = tonumber(redis.call('HGET', KEYS[1], 'tat'))
+if not tat or tat < now then
+	tat = now
+end
+local allowed, retry = 0, 0
+local allow_at = tat + interval - tolerance
+if now < allow_at then
+	retry = math.ceil(allow_at - now)
+else
+	tat = tat + interval
+	allowed = 1
+	redis.call('HSET', KEYS[1], 'tat', string.format('%d', math.ceil(tat)))
+	redis.call('PEXPIRE', KEYS[1], ARGV[5])
+end
+return {allowed, burst, math.floor((now - tat + tolerance) / interval), math.ceil(tat - now), retry}
+`)
+
+	slidingWindowCounterScript = redis.NewScript(`
+local now, period, rate = tonumber(ARGV[1]), tonumber(ARGV[2]), tonumber(ARGV[3])
+local window = now - now % period
+local state = redis.call('HMGET', KEYS[1], 'window', 'count', 'prev')
+local count, prev = tonumber(state[2]) or 0, tonumber(state[3]) or 0
+local last = tonumber(state[1])
+if last ~= window then
+	if last and window - last == period then
+		prev = count
+	else
+		prev = 0
+	end
+	count = 0
+end
+local elapsed = now - window
+local estimate = prev * (1 - elapsed / period) + count
+local allowed, retry = 0, 0
+if estimate >= rate then
+	local free = rate - count
+	if free > 0 and prev > 0 then
+		retry = math.floor((1 - free / prev) * period) - elapsed
+	else
+		retry = period - elapsed
+	end
+else
+	count = count + 1
+	estimate = estimate + 1
+	allowed = 1
+end
+redis.call('HSET', KEYS[1], 'window', string.format('%d', window), 'count', count, 'prev', prev)
+redis.call('PEXPIRE', KEYS[1], ARGV[5])
+local reset = period - elapsed
+if count > 0 then
+	reset = reset + period
+end
+return {allowed, rate, math.max(0, rate - math.ceil(estimate)), reset, retry}
+`)
+
+	// slidingWindowLogScript 用有序集合记录窗口内每个请求的时间
+	slidingWindowLogScript = redis.NewScript(`
+local now, period, rate = tonumber(ARGV[1]), tonumber(ARGV[2]), tonumber(ARGV[3])
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', string.format('%d', now - period))
+local n = redis.call('ZCARD', KEYS[1])
+local allowed, retry = 0, 0
+if n >= rate then
+	local oldest = redis.call('ZRANGE', KEYS[1], n - rate, n - rate, 'WITHSCORES')
+	retry = tonumber(oldest[2]) + period - now
+else
+	redis.call('ZADD', KEYS[1], ARGV[1], ARGV[6])
+	redis.call('PEXPIRE', KEYS[1], ARGV[5])
+	n = n + 1
+	allowed = 1
+end
+local reset = 0
+local newest = redis.call('ZRANGE', KEYS[1], -1, -1, 'WITHSCORES')
+if newest[2] then
+	reset = tonumber(newest[2]) + period - now
+end
+return {allowed, rate, rate - n, reset, retry}
+`)
+)
+
+// algorithmScript 返回内置算法对应的 Lua 脚本，其他算法返回 nil。
+// 使用类型判断而不是 map，自定义算法的类型不一定可以比较
+func algorithmScript(algorithm Algorithm) *redis.Script {
+	switch algorithm.(type) {
+	case tokenBucket:
+		return tokenBucketScript
+	case gcra:
+		return gcraScript
+	case slidingWindowCounter:
+		return slidingWindowCounterScript
+	case slidingWindowLog:
+		return slidingWindowLogScript
+	}
+	return nil
+}
+
+// redisStorageLocks 是 RedisStorage 进程内分段锁的数量
+const redisStorageLocks = 64
+
+// RedisStorage 是基于 Redis 的限流存储，多个实例共享同一份限流状态。
+// 内置算法整个在 Lua 脚本中执行，一次往返完成检查和更新，不会因为并发而冲突。
+// 其他 Algorithm 通过 Update 读取状态后在本地计算，再用 Lua 脚本比较版本号写回；
+// 同一进程内对同一个 key 的更新先经过本地锁排队，版本冲突只会发生在不同实例之间，
+// 重试次数用完时 RateLimiter 按拒绝处理
+type RedisStorage struct {
+	client  *redis.Client
+	prefix  string
+	retries int
+	locks   [redisStorageLocks]sync.Mutex
+}
+
+// RedisStorageOption 是 RedisStorage 的配置项
+type RedisStorageOption func(*RedisStorage)
+
+// WithRedisPrefix 设置 key 前缀，默认为 "ratelimit:"
+func WithRedisPrefix(prefix string) RedisStorageOption {
+	return func(s *RedisStorage) {
+		s.prefix = prefix
+	}
+}
+
+// WithRedisRetries 设置版本冲突时的重试次数
+func WithRedisRetries(retries int) RedisStorageOption {
+	return func(s *RedisStorage) {
+		s.retries = retries
+	}
+}
+
+// NewRedisStorage 创建一个新的 RedisStorage
+func NewRedisStorage(client *redis.Client, opts ...RedisStorageOption) *RedisStorage {
+	s := &RedisStorage{
+		client:  client,
+		prefix:  "ratelimit:",
+		retries: DefaultRedisStorageRetries,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Update 读取状态和版本号，在本地执行 fn 后通过 Lua 脚本比较版本并写回，冲突时重试，
+// 重试次数用完时返回 ErrStorageConflict
+func (s *RedisStorage) Update(key string, ttl time.Duration, fn func(state *State)) error {
+	ctx := context.Background()
+	key = s.prefix + key
+
+	lock := s.lockFor(key)
+	lock.Lock()
+	defer lock.Unlock()
+
+	for i := 0; i <= s.retries; i++ {
+		vals, err := s.client.HMGet(ctx, key, "v", "s").Result()
+		if err != nil {
+			return err
+		}
+		version := "0"
+		var state State
+		if v, ok := vals[0].(string); ok {
+			version = v
+		}
+		if data, ok := vals[1].(string); ok {
+			if err := json.Unmarshal([]byte(data), &state); err != nil {
+				return err
+			}
+		}
+
+		fn(&state)
+		data, err := json.Marshal(&state)
+		if err != nil {
+			return err
+		}
+
+		ok, err := compareAndSetScript.Run(ctx, s.client, []string{key}, version, data, strconv.FormatInt(ttl.Milliseconds(), 10)).Int()
+		if err != nil {
+			return err
+		}
+		if ok == 1 {
+			return nil
+		}
+	}
+	return ErrStorageConflict
+}
+
+// take 在 Redis 中执行内置算法，algorithm 不是内置算法时返回 false
+func (s *RedisStorage) take(key string, algorithm Algorithm, limit Limit, now time.Time) (Result, bool, error) {
+	script := algorithmScript(algorithm)
+	if script == nil {
+		return Result{}, false, nil
+	}
+	key = s.prefix + key
+	if script == slidingWindowLogScript {
+		// 有序集合和其他算法的哈希不能共用一个 key
+		key += ":log"
+	}
+	args := []interface{}{
+		now.UnixMicro(), limit.Period.Microseconds(), limit.Rate, limit.burst(),
+		limit.ttl().Milliseconds(), strconv.FormatInt(now.UnixNano(), 36) + "-" + strconv.FormatUint(uint64(rand.Uint32()), 36),
+	}
+	vals, err := script.Run(context.Background(), s.client, []string{key}, args...).Int64Slice()
+	if err != nil {
+		return Result{}, true, err
+	}
+	if len(vals) != 5 {
+		return Result{}, true, errors.New("unexpected rate limit script result")
+	}
+	return Result{
+		Allowed:    vals[0] == 1,
+		Limit:      int(vals[1]),
+		Remaining:  int(vals[2]),
+		ResetAfter: time.Duration(vals[3]) * time.Microsecond,
+		RetryAfter: time.Duration(vals[4]) * time.Microsecond,
+	}, true, nil
+}
+
+// lockFor 返回 key 对应的本地分段锁
+func (s *RedisStorage) lockFor(key string) *sync.Mutex {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return &s.locks[h.Sum32()%redisStorageLocks]
+}
@@ -1,84 +1,30 @@
 package minutil
 
 import (
-	"crypto/sha256"
-	"encoding/hex"
-	"net/http"
-	"sync"
-	"time"
-
-	"github.com/gin-gonic/gin"
+	"github.com/yowaimono/min-util/ratelimit"
 )
 
-// Storage 接口定义了存储后端需要实现的方法
-type Storage interface {
-	Get(key string) (time.Time, bool)
-	Set(key string, value time.Time)
-}
-
-// RateLimiter 是一个简单的限流器，使用自定义的存储后端
-type RateLimiter struct {
-	storage Storage
-}
-
-// NewRateLimiter 创建一个新的 RateLimiter 实例
-func NewRateLimiter(storage Storage) *RateLimiter {
-	return &RateLimiter{
-		storage: storage,
-	}
-}
-
-// Allow 检查是否允许请求
-func (rl *RateLimiter) Allow(key string, duration time.Duration) bool {
-	now := time.Now()
-	if val, ok := rl.storage.Get(key); ok {
-		lastRequestTime := val
-		if now.Sub(lastRequestTime) < duration {
-			return false
-		}
-	}
-	rl.storage.Set(key, now)
-	return true
-}
-
-// generateKey 生成一个唯一的键，基于用户 IP 和 User-Agent
-func generateKey(c *gin.Context) string {
-	ip := c.ClientIP()
-	userAgent := c.GetHeader("User-Agent")
-	hash := sha256.Sum256([]byte(ip + userAgent))
-	return hex.EncodeToString(hash[:])
-}
+// 限流器已经移到不依赖 Gin 的 ratelimit 包，限流中间件移到 middleware 包，
+// 这里保留旧名称以兼容已有代码
 
-// RateLimitMiddleware 是一个 Gin 中间件，用于限制重复请求
-func RateLimitMiddleware(limiter *RateLimiter, duration time.Duration) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		key := generateKey(c)
-		if !limiter.Allow(key, duration) {
-			c.JSON(http.StatusTooManyRequests, gin.H{
-				"message": "Too many requests, please try again later.",
-			})
-			c.Abort()
-			return
-		}
-		c.Next()
-	}
-}
+// Storage 是限流器的存储后端
+//
+// Deprecated: 使用 ratelimit.Storage
+type Storage = ratelimit.Storage
 
-// 示例：使用 sync.Map 作为存储后端
-type SyncMapStorage struct {
-	cache sync.Map
-}
+// RateLimiter 是一个限流器
+//
+// Deprecated: 使用 ratelimit.RateLimiter
+type RateLimiter = ratelimit.RateLimiter
 
-func (s *SyncMapStorage) Get(key string) (time.Time, bool) {
-	if val, ok := s.cache.Load(key); ok {
-		return val.(time.Time), true
-	}
-	return time.Time{}, false
-}
+// SyncMapStorage 是基于 sync.Map 的存储后端
+//
+// Deprecated: 使用 ratelimit.MemoryStorage
+type SyncMapStorage = ratelimit.SyncMapStorage
 
-func (s *SyncMapStorage) Set(key string, value time.Time) {
-	s.cache.Store(key, value)
+// NewRateLimiter 创建一个新的 RateLimiter 实例
+//
+// Deprecated: 使用 ratelimit.NewRateLimiter
+func NewRateLimiter(storage Storage, opts ...ratelimit.LimiterOption) *RateLimiter {
+	return ratelimit.NewRateLimiter(storage, opts...)
 }
-
-// 示例：使用 Redis 作为存储后端
-// 你可以根据需要实现 RedisStorage
\ No newline at end of file
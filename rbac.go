@@ -0,0 +1,112 @@
+package minutil
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Policy 是角色到权限的映射。权限支持通配符：
+// "*" 表示全部权限，"order:*" 表示 order 下的全部权限
+type Policy struct {
+	mu    sync.RWMutex
+	roles map[string][]string
+}
+
+// policyFile 是策略文件的格式：{"roles": {"admin": ["*"], "editor": ["order:read"]}}
+type policyFile struct {
+	Roles map[string][]string `json:"roles"`
+}
+
+// defaultPolicy 是 middleware.RequirePermission 使用的策略，可以在路由注册之后替换
+var defaultPolicy atomic.Pointer[Policy]
+
+func init() {
+	defaultPolicy.Store(NewPolicy())
+}
+
+// NewPolicy 创建一个空的策略
+func NewPolicy() *Policy {
+	return &Policy{
+		roles: make(map[string][]string),
+	}
+}
+
+// LoadPolicy 从 JSON 中读取策略
+func LoadPolicy(r io.Reader) (*Policy, error) {
+	var file policyFile
+	if err := json.NewDecoder(r).Decode(&file); err != nil {
+		return nil, err
+	}
+	p := NewPolicy()
+	for role, permissions := range file.Roles {
+		p.Grant(role, permissions...)
+	}
+	return p, nil
+}
+
+// LoadPolicyFile 从 JSON 文件中读取策略
+func LoadPolicyFile(path string) (*Policy, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return LoadPolicy(f)
+}
+
+// SetDefaultPolicy 设置 middleware.RequirePermission 使用的策略，对已经注册的路由同样生效，p 为 nil 时使用空策略
+func SetDefaultPolicy(p *Policy) {
+	if p == nil {
+		p = NewPolicy()
+	}
+	defaultPolicy.Store(p)
+}
+
+// DefaultPolicy 返回 middleware.RequirePermission 使用的策略
+func DefaultPolicy() *Policy {
+	return defaultPolicy.Load()
+}
+
+// Grant 为角色授予权限，并返回当前策略
+func (p *Policy) Grant(role string, permissions ...string) *Policy {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.roles[role] = append(p.roles[role], permissions...)
+	return p
+}
+
+// Permissions 返回角色拥有的权限
+func (p *Policy) Permissions(role string) []string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return append([]string(nil), p.roles[role]...)
+}
+
+// Allowed 检查任一角色是否拥有指定权限
+func (p *Policy) Allowed(roles []string, permission string) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	for _, role := range roles {
+		for _, granted := range p.roles[role] {
+			if matchPermission(granted, permission) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// matchPermission 检查授予的权限是否覆盖请求的权限
+func matchPermission(granted, permission string) bool {
+	if granted == "*" || granted == permission {
+		return true
+	}
+	if prefix, ok := strings.CutSuffix(granted, "*"); ok {
+		return strings.HasPrefix(permission, prefix)
+	}
+	return false
+}
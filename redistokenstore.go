@@ -2,27 +2,99 @@ package minutil
 
 import (
 	"context"
-
+	"strconv"
+	"time"
 
 	"github.com/go-redis/redis/v8"
 )
 
+// DefaultRedisTokenPrefix 是 RedisTokenStore 默认的 key 前缀，避免与应用自己的 key 冲突
+const DefaultRedisTokenPrefix = "minutil:token:"
+
+// 各类记录在前缀之后的 key
+const (
+	redisRevokedPrefix = "revoked:"
+	redisRefreshPrefix = "refresh:"
+	redisFamilyPrefix  = "family:"
+	redisUserPrefix    = "user_nbf:"
+	redisSessionPrefix = "session:"
+	redisSessionsOf    = "user_sessions:"
+)
+
+// useRefreshScript 在刷新 Token 存在时递增使用次数，不存在时返回 -1，
+// HINCRBY 不会改变 key 的过期时间
+var useRefreshScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	return -1
+end
+return redis.call('HINCRBY', KEYS[1], 'uses', 1)
+`)
+
+// saveSessionScript 写入会话哈希并加入用户的会话集合，
+// 集合的过期时间取其中最晚过期的会话，有效期小于等于 0 时删除会话
+var saveSessionScript = redis.NewScript(`
+local ttl = tonumber(ARGV[1])
+redis.call('DEL', KEYS[1])
+if ttl <= 0 then
+	redis.call('SREM', KEYS[2], ARGV[2])
+	return 0
+end
+redis.call('HSET', KEYS[1], unpack(ARGV, 3))
+redis.call('PEXPIRE', KEYS[1], ttl)
+local existed = redis.call('EXISTS', KEYS[2])
+redis.call('SADD', KEYS[2], ARGV[2])
+local current = redis.call('PTTL', KEYS[2])
+if existed == 0 or (current >= 0 and current < ttl) then
+	redis.call('PEXPIRE', KEYS[2], ttl)
+end
+return 1
+`)
+
+// touchSessionScript 只在会话存在时更新最近访问时间，避免重新创建没有过期时间的 key
+var touchSessionScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 1 then
+	redis.call('HSET', KEYS[1], 'last_seen', ARGV[1])
+end
+return 1
+`)
+
 // RedisTokenStore 是基于 Redis 的 Token 存储实现
 type RedisTokenStore struct {
 	client *redis.Client
+	prefix string
+}
+
+// RedisTokenStoreOption 是 RedisTokenStore 的配置项
+type RedisTokenStoreOption func(*RedisTokenStore)
+
+// WithRedisPrefix 设置 key 前缀，默认为 DefaultRedisTokenPrefix，多个应用共用一个 Redis 时可以分别设置
+func WithRedisPrefix(prefix string) RedisTokenStoreOption {
+	return func(r *RedisTokenStore) {
+		r.prefix = prefix
+	}
 }
 
 // NewRedisTokenStore 创建一个新的 RedisTokenStore
-func NewRedisTokenStore(client *redis.Client) *RedisTokenStore {
-	return &RedisTokenStore{
+func NewRedisTokenStore(client *redis.Client, opts ...RedisTokenStoreOption) *RedisTokenStore {
+	r := &RedisTokenStore{
 		client: client,
+		prefix: DefaultRedisTokenPrefix,
+	}
+	for _, opt := range opts {
+		opt(r)
 	}
+	return r
+}
+
+// key 返回一类记录的完整 key
+func (r *RedisTokenStore) key(kind, id string) string {
+	return r.prefix + kind + id
 }
 
 // Get 获取 Token 的状态
-func (r *RedisTokenStore) Get(token string) (bool, error) {
+func (r *RedisTokenStore) Get(id string) (bool, error) {
 	ctx := context.Background()
-	val, err := r.client.Get(ctx, token).Result()
+	val, err := r.client.Get(ctx, r.key(redisRevokedPrefix, id)).Result()
 	if err == redis.Nil {
 		return false, nil
 	} else if err != nil {
@@ -31,12 +103,177 @@ func (r *RedisTokenStore) Get(token string) (bool, error) {
 	return val == "1", nil
 }
 
-// Set 设置 Token 的状态
-func (r *RedisTokenStore) Set(token string, invalid bool) error {
+// Set 设置 Token 的状态，使用 Redis 原生 TTL，expiration 为 0 时永久保存
+func (r *RedisTokenStore) Set(id string, invalid bool, expiration time.Duration) error {
 	ctx := context.Background()
 	val := "0"
 	if invalid {
 		val = "1"
 	}
-	return r.client.Set(ctx, token, val, 0).Err()
-}
\ No newline at end of file
+	return r.client.Set(ctx, r.key(redisRevokedPrefix, id), val, expiration).Err()
+}
+
+// SaveRefreshToken 记录一个尚未使用的刷新 Token
+func (r *RedisTokenStore) SaveRefreshToken(id string, expiration time.Duration) error {
+	ctx := context.Background()
+	key := r.key(redisRefreshPrefix, id)
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, key, "uses", 0)
+		pipe.Expire(ctx, key, expiration)
+		return nil
+	})
+	return err
+}
+
+// UseRefreshToken 原子地把刷新 Token 标记为已使用，返回它之前是否已被使用
+func (r *RedisTokenStore) UseRefreshToken(id string) (bool, error) {
+	ctx := context.Background()
+	uses, err := useRefreshScript.Run(ctx, r.client, []string{r.key(redisRefreshPrefix, id)}).Int64()
+	if err != nil {
+		return false, err
+	}
+	if uses < 0 {
+		return false, ErrRefreshTokenNotFound
+	}
+	return uses > 1, nil
+}
+
+// RevokeFamily 撤销整条刷新链
+func (r *RedisTokenStore) RevokeFamily(family string, expiration time.Duration) error {
+	ctx := context.Background()
+	return r.client.Set(ctx, r.key(redisFamilyPrefix, family), "1", expiration).Err()
+}
+
+// IsFamilyRevoked 检查刷新链是否已被撤销
+func (r *RedisTokenStore) IsFamilyRevoked(family string) (bool, error) {
+	ctx := context.Background()
+	n, err := r.client.Exists(ctx, r.key(redisFamilyPrefix, family)).Result()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// SetUserNotBefore 设置用户的失效水位线，以毫秒时间戳保存
+func (r *RedisTokenStore) SetUserNotBefore(userID string, notBefore time.Time) error {
+	ctx := context.Background()
+	return r.client.Set(ctx, r.key(redisUserPrefix, userID), notBefore.UnixMilli(), 0).Err()
+}
+
+// GetUserNotBefore 返回用户的失效水位线
+func (r *RedisTokenStore) GetUserNotBefore(userID string) (time.Time, error) {
+	ctx := context.Background()
+	ms, err := r.client.Get(ctx, r.key(redisUserPrefix, userID)).Int64()
+	if err == redis.Nil {
+		return time.Time{}, nil
+	} else if err != nil {
+		return time.Time{}, err
+	}
+	return time.UnixMilli(ms), nil
+}
+
+// SaveSession 创建或覆盖会话
+func (r *RedisTokenStore) SaveSession(session *Session, expiration time.Duration) error {
+	ctx := context.Background()
+	keys := []string{r.key(redisSessionPrefix, session.ID), r.key(redisSessionsOf, session.UserID)}
+	args := []interface{}{
+		expiration.Milliseconds(), session.ID,
+		"user_id", session.UserID,
+		"token_id", session.TokenID,
+		"user_agent", session.UserAgent,
+		"ip", session.IP,
+		"issued_at", session.IssuedAt.UnixMilli(),
+		"expires_at", session.ExpiresAt.UnixMilli(),
+		"last_seen", session.LastSeen.UnixMilli(),
+	}
+	return saveSessionScript.Run(ctx, r.client, keys, args...).Err()
+}
+
+// GetSession 返回会话，不存在时返回 nil
+func (r *RedisTokenStore) GetSession(id string) (*Session, error) {
+	ctx := context.Background()
+	fields, err := r.client.HGetAll(ctx, r.key(redisSessionPrefix, id)).Result()
+	if err != nil {
+		return nil, err
+	}
+	return sessionFromHash(id, fields), nil
+}
+
+// ListSessions 返回用户的全部有效会话，并清理集合中已过期的会话 ID
+func (r *RedisTokenStore) ListSessions(userID string) ([]*Session, error) {
+	ctx := context.Background()
+	ids, err := r.client.SMembers(ctx, r.key(redisSessionsOf, userID)).Result()
+	if err != nil {
+		return nil, err
+	}
+	cmds := make([]*redis.StringStringMapCmd, len(ids))
+	_, err = r.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, id := range ids {
+			cmds[i] = pipe.HGetAll(ctx, r.key(redisSessionPrefix, id))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sessions := make([]*Session, 0, len(ids))
+	var stale []interface{}
+	for i, cmd := range cmds {
+		if session := sessionFromHash(ids[i], cmd.Val()); session != nil {
+			sessions = append(sessions, session)
+		} else {
+			stale = append(stale, ids[i])
+		}
+	}
+	if len(stale) > 0 {
+		if err := r.client.SRem(ctx, r.key(redisSessionsOf, userID), stale...).Err(); err != nil {
+			return nil, err
+		}
+	}
+	return sessions, nil
+}
+
+// TouchSession 更新会话的最近访问时间
+func (r *RedisTokenStore) TouchSession(id string, lastSeen time.Time) error {
+	ctx := context.Background()
+	return touchSessionScript.Run(ctx, r.client, []string{r.key(redisSessionPrefix, id)}, lastSeen.UnixMilli()).Err()
+}
+
+// DeleteSession 删除会话
+func (r *RedisTokenStore) DeleteSession(id string) error {
+	ctx := context.Background()
+	key := r.key(redisSessionPrefix, id)
+	userID, err := r.client.HGet(ctx, key, "user_id").Result()
+	if err == redis.Nil {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	_, err = r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, key)
+		pipe.SRem(ctx, r.key(redisSessionsOf, userID), id)
+		return nil
+	})
+	return err
+}
+
+// sessionFromHash 把 Redis 哈希转换为会话，哈希为空时返回 nil
+func sessionFromHash(id string, fields map[string]string) *Session {
+	if len(fields) == 0 {
+		return nil
+	}
+	millis := func(name string) time.Time {
+		ms, _ := strconv.ParseInt(fields[name], 10, 64)
+		return time.UnixMilli(ms)
+	}
+	return &Session{
+		ID:        id,
+		UserID:    fields["user_id"],
+		TokenID:   fields["token_id"],
+		UserAgent: fields["user_agent"],
+		IP:        fields["ip"],
+		IssuedAt:  millis("issued_at"),
+		ExpiresAt: millis("expires_at"),
+		LastSeen:  millis("last_seen"),
+	}
+}
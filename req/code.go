@@ -43,6 +43,7 @@ const (
 	ErrResourceAlreadyExists
 	ErrResourceNotFound
 	ErrOperationFailed
+	ErrTooManyRequests
 )
 
 // 定义枚举对应的错误信息
@@ -85,4 +86,5 @@ var errorMessages = map[ErrorCode]string{
 	ErrResourceAlreadyExists: "Resource already exists!",
 	ErrResourceNotFound:    "Resource not found!",
 	ErrOperationFailed:     "Operation failed!",
+	ErrTooManyRequests:     "Too many requests, please try again later.",
 }
@@ -31,3 +31,15 @@ func Err[T any](c *gin.Context, code int, message string) {
 	})
 }
 
+// 定义一个工厂方法来创建业务相关的错误响应
+func Of(c *gin.Context, code ErrorCode) {
+	Err[string](c, int(code), errorMessages[code])
+}
+
+// 定义一个工厂方法来创建带 HTTP 状态码的业务错误响应，例如 429
+func OfStatus(c *gin.Context, status int, code ErrorCode) {
+	c.JSON(status, Req[string]{
+		Code:    int(code),
+		Message: errorMessages[code],
+	})
+}
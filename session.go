@@ -0,0 +1,129 @@
+package minutil
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrSessionNotFound 表示会话不存在或不属于该用户
+var ErrSessionNotFound = errors.New("session not found")
+
+// DefaultLastSeenInterval 是会话最近访问时间的默认更新间隔
+const DefaultLastSeenInterval = time.Minute
+
+// maxTouchedSessions 是 JWTTokenManager 在内存中记录更新时间的会话数上限
+const maxTouchedSessions = 10000
+
+// Session 是一次登录产生的会话，对应 Token 中的 Family，刷新 Token 时会话保持不变
+type Session struct {
+	ID        string    `json:"id"`
+	UserID    string    `json:"user_id"`
+	TokenID   string    `json:"token_id"` // 最近一次签发的访问 Token 的 jti
+	UserAgent string    `json:"user_agent,omitempty"`
+	IP        string    `json:"ip,omitempty"`
+	IssuedAt  time.Time `json:"issued_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+	LastSeen  time.Time `json:"last_seen"` // 精确到 WithLastSeenInterval 设置的间隔
+}
+
+// deviceInfo 是签发 Token 时传入的设备信息
+type deviceInfo struct {
+	userAgent string
+	ip        string
+}
+
+// ListSessions 返回用户当前有效的会话，用于展示已登录设备
+func (tm *JWTTokenManager) ListSessions(userID string) ([]*Session, error) {
+	if tm.store == nil {
+		return nil, ErrStoreNotConfigured
+	}
+	return tm.store.ListSessions(userID)
+}
+
+// RevokeSession 撤销用户的某个会话，该会话下的访问 Token 和刷新 Token 全部失效
+func (tm *JWTTokenManager) RevokeSession(userID, sessionID string) error {
+	if tm.store == nil {
+		return ErrStoreNotConfigured
+	}
+	session, err := tm.store.GetSession(sessionID)
+	if err != nil {
+		return err
+	}
+	if session == nil || session.UserID != userID {
+		return ErrSessionNotFound
+	}
+	// 会话下的 Token 已经全部过期时只需删除会话，不能写入没有过期时间的撤销记录
+	if ttl := time.Until(session.ExpiresAt) + tm.leeway; ttl > 0 {
+		if err := tm.store.RevokeFamily(sessionID, ttl); err != nil {
+			return err
+		}
+	}
+	return tm.store.DeleteSession(sessionID)
+}
+
+// recordSession 在签发 Token 后创建或更新会话，prev 是刷新前的会话，已经过期的 Token 不记录会话
+func (tm *JWTTokenManager) recordSession(claims *TokenClaims, expiresAt time.Time, prev *Session) error {
+	if tm.store == nil {
+		return nil
+	}
+	ttl := time.Until(expiresAt) + tm.leeway
+	if ttl <= 0 {
+		return nil
+	}
+	now := time.Now()
+	session := &Session{
+		ID:        claims.Family,
+		UserID:    claims.UserID,
+		TokenID:   claims.Id,
+		UserAgent: claims.device.userAgent,
+		IP:        claims.device.ip,
+		IssuedAt:  now,
+		ExpiresAt: expiresAt,
+		LastSeen:  now,
+	}
+	if prev != nil {
+		session.IssuedAt = prev.IssuedAt
+		if session.UserAgent == "" {
+			session.UserAgent = prev.UserAgent
+			session.IP = prev.IP
+		}
+	}
+	return tm.store.SaveSession(session, ttl)
+}
+
+// touchSession 更新会话的最近访问时间，同一会话在 lastSeenInterval 内只写一次存储
+func (tm *JWTTokenManager) touchSession(claims *TokenClaims) error {
+	if tm.store == nil || claims.Family == "" || tm.lastSeenInterval < 0 {
+		return nil
+	}
+	now := time.Now()
+	if !tm.shouldTouch(claims.Family, now) {
+		return nil
+	}
+	return tm.store.TouchSession(claims.Family, now)
+}
+
+// shouldTouch 检查会话距离上次写入是否已经超过 lastSeenInterval，需要写入时记录本次时间
+func (tm *JWTTokenManager) shouldTouch(family string, now time.Time) bool {
+	tm.touchMu.Lock()
+	defer tm.touchMu.Unlock()
+	if last, ok := tm.touched[family]; ok && now.Sub(last) < tm.lastSeenInterval {
+		return false
+	}
+	if tm.touched == nil {
+		tm.touched = make(map[string]time.Time)
+	}
+	if len(tm.touched) >= maxTouchedSessions {
+		for k, last := range tm.touched {
+			if now.Sub(last) >= tm.lastSeenInterval {
+				delete(tm.touched, k)
+			}
+		}
+		if len(tm.touched) >= maxTouchedSessions {
+			// 间隔内活跃的会话过多时全部重新计时，最多多写一次存储
+			clear(tm.touched)
+		}
+	}
+	tm.touched[family] = now
+	return true
+}
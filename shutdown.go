@@ -43,6 +43,7 @@ func RunServer(router *gin.Engine, addr string) {
 	}
 
 	Info("Server Exiting")
+	GetLogger().Sync()
 }
 
 // func main() {
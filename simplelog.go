@@ -1,101 +1,438 @@
 package minutil
 
 import (
+	"errors"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
 	"os"
 	"path"
 	"runtime"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
-// Logger 是自定义的日志记录器
+// Level 是日志级别
+type Level int32
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+	LevelFatal
+)
+
+// String 返回级别的名称
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "DEBUG"
+	case LevelInfo:
+		return "INFO"
+	case LevelWarn:
+		return "WARN"
+	case LevelError:
+		return "ERROR"
+	case LevelFatal:
+		return "FATAL"
+	default:
+		return fmt.Sprintf("LEVEL(%d)", int32(l))
+	}
+}
+
+// MarshalText 把级别编码为名称，JSON 中输出为 "INFO" 而不是数字
+func (l Level) MarshalText() ([]byte, error) {
+	return []byte(l.String()), nil
+}
+
+// UnmarshalText 从名称解析级别
+func (l *Level) UnmarshalText(text []byte) error {
+	level, err := ParseLevel(string(text))
+	if err != nil {
+		return err
+	}
+	*l = level
+	return nil
+}
+
+// ParseLevel 解析级别名称，不区分大小写
+func ParseLevel(s string) (Level, error) {
+	switch strings.ToUpper(strings.TrimSpace(s)) {
+	case "DEBUG":
+		return LevelDebug, nil
+	case "INFO":
+		return LevelInfo, nil
+	case "WARN", "WARNING":
+		return LevelWarn, nil
+	case "ERROR":
+		return LevelError, nil
+	case "FATAL":
+		return LevelFatal, nil
+	}
+	return LevelDebug, fmt.Errorf("unknown log level %q", s)
+}
+
+// Field 是日志中的一个键值对
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// F 创建一个键值对
+func F(key string, value interface{}) Field {
+	return Field{Key: key, Value: value}
+}
+
+// Caller 是打印日志的代码位置
+type Caller struct {
+	Package  string
+	File     string
+	Line     int
+	Function string
+}
+
+// Entry 是一条日志
+type Entry struct {
+	Time    time.Time
+	Level   Level
+	Message string
+	Caller  Caller
+	Fields  []Field
+	pc      uintptr // 调用方的程序计数器，转换为 slog.Record 时使用
+}
+
+// Logger 是自定义的日志记录器，支持最低级别过滤和结构化字段。
+// 通过 With 创建的子记录器共享输出和级别
 type Logger struct {
-	logger *log.Logger
+	core   *loggerCore
+	fields []Field
+}
+
+// loggerCore 是父子记录器共享的部分
+type loggerCore struct {
+	mu       sync.Mutex
+	sinks    []sink
+	samplers map[Level]*sampler // 各级别的采样器，未配置采样的级别没有对应的键
+
+	levelMu  sync.RWMutex
+	level    atomic.Int32     // 全局最低级别
+	minLevel atomic.Int32     // 全局和各包级别中最低的，用于在获取调用位置之前快速过滤
+	packages map[string]Level // 包级别，键为包所在目录名
+}
+
+// sink 是一个输出及其格式，encoder 为 nil 时根据输出是否为终端选择 ConsoleEncoder 的颜色。
+// handler 不为 nil 时日志转交给 slog.Handler，不使用 out 和 encoder
+type sink struct {
+	out     io.Writer
+	encoder Encoder
+	handler slog.Handler
+}
+
+// LoggerOption 是 Logger 的配置项
+type LoggerOption func(*loggerCore)
+
+// WithLevel 设置最低输出级别，默认为 LevelDebug
+func WithLevel(level Level) LoggerOption {
+	return func(c *loggerCore) {
+		c.setLevel(level)
+	}
+}
+
+// WithEncoder 设置主输出的日志格式，默认为 ConsoleEncoder，输出不是终端时不带颜色
+func WithEncoder(encoder Encoder) LoggerOption {
+	return func(c *loggerCore) {
+		c.sinks[0].encoder = encoder
+	}
+}
+
+// WithOutput 设置主输出，默认为 os.Stdout
+func WithOutput(w io.Writer) LoggerOption {
+	return func(c *loggerCore) {
+		c.sinks[0].out = w
+	}
+}
+
+// WithSink 增加一个输出，日志会同时写入所有输出，encoder 为 nil 时与主输出的默认格式相同，例如：
+//
+//	file, _ := NewRotatingFile("logs/app.log", WithMaxSize(100<<20), WithMaxBackups(7))
+//	logger := NewLogger(WithSink(NewAsyncWriter(file, 0), &JSONEncoder{}))
+func WithSink(w io.Writer, encoder Encoder) LoggerOption {
+	return func(c *loggerCore) {
+		c.sinks = append(c.sinks, sink{out: w, encoder: encoder})
+	}
+}
+
+// NewLogger 创建一个新的 Logger
+func NewLogger(opts ...LoggerOption) *Logger {
+	core := &loggerCore{
+		sinks: []sink{{out: os.Stdout}},
+	}
+	for _, opt := range opts {
+		opt(core)
+	}
+	for i := range core.sinks {
+		if core.sinks[i].handler == nil && core.sinks[i].encoder == nil {
+			core.sinks[i].encoder = &ConsoleEncoder{Color: ColorEnabled(core.sinks[i].out)}
+		}
+	}
+	return &Logger{core: core}
 }
 
 var (
-	instanceLog *Logger
+	instanceLog atomic.Pointer[Logger]
 	olog        sync.Once
 )
 
-// GetLogger 返回单例模式的日志记录器
+// GetLogger 返回单例模式的日志记录器，环境变量 MIN_LOG_LEVEL 可以设置最低级别，格式参见 SetLevelSpec
 func GetLogger() *Logger {
+	if l := instanceLog.Load(); l != nil {
+		return l
+	}
 	olog.Do(func() {
-		instanceLog = &Logger{
-			logger: log.New(os.Stdout, "", 0),
+		l := NewLogger()
+		if spec := os.Getenv("MIN_LOG_LEVEL"); spec != "" {
+			l.SetLevelSpec(spec)
 		}
+		instanceLog.CompareAndSwap(nil, l)
 	})
-	return instanceLog
+	return instanceLog.Load()
 }
 
-// logf 是日志记录的通用函数
-func (l *Logger) logf(level, format string, v ...interface{}) {
-	now := time.Now().Format("2006-01-02 15:04:05")
+// SetLogger 替换包级日志函数使用的记录器
+func SetLogger(l *Logger) {
+	instanceLog.Store(l)
+}
 
-	//     _, filename, _, _ := runtime.Caller(1)
-	//     return path.Base(path.Dir(filename))
+// With 返回带有额外字段的子记录器，参数为交替的键和值，或者 Field
+func (l *Logger) With(kv ...interface{}) *Logger {
+	fields := make([]Field, 0, len(l.fields)+len(kv)/2)
+	fields = append(fields, l.fields...)
+	return &Logger{core: l.core, fields: appendFields(fields, kv)}
+}
 
-	_, file, line, _ := runtime.Caller(2)
-	pkname := path.Base(path.Dir(file))
-	file = file[strings.LastIndex(file, "/")+1:]
-	message := fmt.Sprintf(format, v...)
+// log 是日志记录的通用函数，skip 是从调用方到 log 的栈帧数
+func (l *Logger) log(skip int, level Level, message string, kv []interface{}) {
+	if !l.Enabled(level) {
+		return
+	}
+	caller, pc := getCaller(skip + 1)
+	if level < l.core.levelFor(caller.Package) {
+		return
+	}
+	entry := &Entry{
+		Time:    time.Now(),
+		Level:   level,
+		Message: message,
+		Caller:  caller,
+		Fields:  appendFields(append([]Field(nil), l.fields...), kv),
+		pc:      pc,
+	}
+	l.emit(entry)
+}
 
-	// 获取函数名
-	pc, _, _, _ := runtime.Caller(2)
-	function := runtime.FuncForPC(pc).Name()
-	function = function[strings.LastIndex(function, ".")+1:]
+// emit 按采样策略决定是否输出一条日志，需要时先输出被抑制日志的汇总
+func (l *Logger) emit(entry *Entry) {
+	if s := l.core.samplers[entry.Level]; s != nil {
+		allowed, summaries := s.sample(entry)
+		for _, summary := range summaries {
+			l.core.write(summary)
+		}
+		if !allowed {
+			return
+		}
+	}
+	l.core.write(entry)
+}
 
-	// 获取包名
-	// packageName := runtime.FuncForPC(pc).Name()
-	// packageName = packageName[:strings.LastIndex(packageName, ".")]
-	// packageName = packageName[strings.LastIndex(packageName, "/")+1:]
+// logf 按格式记录日志，级别被过滤时不格式化消息
+func (l *Logger) logf(skip int, level Level, format string, v []interface{}) {
+	if !l.Enabled(level) {
+		return
+	}
+	l.log(skip+1, level, fmt.Sprintf(format, v...), nil)
+}
 
-	// 添加颜色
-	var levelColor string
-	switch level {
-	case "INFO":
-		levelColor = "\033[32m" // 绿色
-	case "ERROR":
-		levelColor = "\033[31m" // 红色
-	case "WARN":
-		levelColor = "\033[33m" // 黄色
-	case "DEBUG":
-		levelColor = "\033[36m" // 青色
-	case "FATAL":
-		levelColor = "\033[35m" // 紫色
-	default:
-		levelColor = "\033[0m" // 默认颜色
+// write 编码并输出一条日志
+func (c *loggerCore) write(entry *Entry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, s := range c.sinks {
+		if s.handler != nil {
+			handleSlog(s.handler, entry)
+			continue
+		}
+		s.out.Write(s.encoder.Encode(entry))
+	}
+}
+
+// Sync 输出尚未汇总的被抑制日志数量，并把缓冲中的日志写入输出，例如 AsyncWriter 和文件
+func (l *Logger) Sync() error {
+	l.core.flushSamplers()
+	l.core.mu.Lock()
+	defer l.core.mu.Unlock()
+	var errs []error
+	for _, s := range l.core.sinks {
+		if syncer, ok := s.out.(interface{ Sync() error }); ok {
+			errs = append(errs, syncer.Sync())
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// flushSamplers 输出全部采样器尚未汇总的被抑制日志数量
+func (c *loggerCore) flushSamplers() {
+	now := time.Now()
+	for _, s := range c.samplers {
+		for _, summary := range s.flush(now) {
+			c.write(summary)
+		}
 	}
+}
 
-	l.logger.Printf("[\033[35mMIN\033[0m] [\033[34m%s\033[0m] [\033[36m%s/%s:%d -> %s\033[0m] [\033[33m%s%s\033[0m] %s", now, pkname, file, line, function, levelColor, level, message)
+// Close 输出尚未汇总的被抑制日志数量，写入缓冲中的日志并关闭全部输出，标准输出和标准错误不会被关闭
+func (l *Logger) Close() error {
+	for _, s := range l.core.samplers {
+		s.close()
+	}
+	l.core.flushSamplers()
+	l.core.mu.Lock()
+	defer l.core.mu.Unlock()
+	var errs []error
+	for _, s := range l.core.sinks {
+		if s.out == os.Stdout || s.out == os.Stderr {
+			continue
+		}
+		if closer, ok := s.out.(io.Closer); ok {
+			errs = append(errs, closer.Close())
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// getCaller 返回调用方的代码位置和程序计数器
+func getCaller(skip int) (Caller, uintptr) {
+	pc, file, line, ok := runtime.Caller(skip + 1)
+	if !ok {
+		return Caller{}, 0
+	}
+	return newCaller(file, line, runtime.FuncForPC(pc).Name()), pc
+}
+
+// newCaller 从文件路径和完整的函数名创建 Caller
+func newCaller(file string, line int, function string) Caller {
+	return Caller{
+		Package:  path.Base(path.Dir(file)),
+		File:     file[strings.LastIndex(file, "/")+1:],
+		Line:     line,
+		Function: function[strings.LastIndex(function, ".")+1:],
+	}
+}
+
+// appendFields 把交替的键和值转换为字段，缺少值的键记为 "!BADKEY"
+func appendFields(fields []Field, kv []interface{}) []Field {
+	for i := 0; i < len(kv); i++ {
+		switch k := kv[i].(type) {
+		case Field:
+			fields = append(fields, k)
+		case string:
+			if i+1 < len(kv) {
+				fields = append(fields, Field{Key: k, Value: kv[i+1]})
+				i++
+			} else {
+				fields = append(fields, Field{Key: "!BADKEY", Value: k})
+			}
+		default:
+			fields = append(fields, Field{Key: "!BADKEY", Value: k})
+		}
+	}
+	return fields
+}
+
+// Log 按指定级别记录日志，kv 为交替的键和值
+func (l *Logger) Log(level Level, msg string, kv ...interface{}) {
+	l.log(1, level, msg, kv)
+}
+
+// Debug 记录调试级别的日志，kv 为交替的键和值
+func (l *Logger) Debug(msg string, kv ...interface{}) {
+	l.log(1, LevelDebug, msg, kv)
+}
+
+// Info 记录信息级别的日志，kv 为交替的键和值
+func (l *Logger) Info(msg string, kv ...interface{}) {
+	l.log(1, LevelInfo, msg, kv)
+}
+
+// Warn 记录警告级别的日志，kv 为交替的键和值
+func (l *Logger) Warn(msg string, kv ...interface{}) {
+	l.log(1, LevelWarn, msg, kv)
+}
+
+// Error 记录错误级别的日志，kv 为交替的键和值
+func (l *Logger) Error(msg string, kv ...interface{}) {
+	l.log(1, LevelError, msg, kv)
+}
+
+// Fatal 记录致命错误级别的日志并退出程序
+func (l *Logger) Fatal(msg string, kv ...interface{}) {
+	l.log(1, LevelFatal, msg, kv)
+	l.Sync()
+	os.Exit(1)
+}
+
+// Debugf 按格式记录调试级别的日志
+func (l *Logger) Debugf(format string, v ...interface{}) {
+	l.logf(1, LevelDebug, format, v)
+}
+
+// Infof 按格式记录信息级别的日志
+func (l *Logger) Infof(format string, v ...interface{}) {
+	l.logf(1, LevelInfo, format, v)
+}
+
+// Warnf 按格式记录警告级别的日志
+func (l *Logger) Warnf(format string, v ...interface{}) {
+	l.logf(1, LevelWarn, format, v)
+}
+
+// Errorf 按格式记录错误级别的日志
+func (l *Logger) Errorf(format string, v ...interface{}) {
+	l.logf(1, LevelError, format, v)
+}
+
+// Fatalf 按格式记录致命错误级别的日志并退出程序
+func (l *Logger) Fatalf(format string, v ...interface{}) {
+	l.logf(1, LevelFatal, format, v)
+	l.Sync()
+	os.Exit(1)
 }
 
 // Info 记录信息级别的日志
 func Info(format string, v ...interface{}) {
-	GetLogger().logf("INFO", format, v...)
+	GetLogger().logf(1, LevelInfo, format, v)
 }
 
 // Error 记录错误级别的日志
 func Error(format string, v ...interface{}) {
-	GetLogger().logf("ERROR", format, v...)
+	GetLogger().logf(1, LevelError, format, v)
 }
 
 // Warn 记录警告级别的日志
 func Warn(format string, v ...interface{}) {
-	GetLogger().logf("WARN", format, v...)
+	GetLogger().logf(1, LevelWarn, format, v)
 }
 
 // Debug 记录调试级别的日志
 func Debug(format string, v ...interface{}) {
-	GetLogger().logf("DEBUG", format, v...)
+	GetLogger().logf(1, LevelDebug, format, v)
 }
 
 // Fatal 记录致命错误级别的日志并退出程序
 func Fatal(format string, v ...interface{}) {
-	GetLogger().logf("FATAL", format, v...)
+	GetLogger().logf(1, LevelFatal, format, v)
+	GetLogger().Sync()
 	os.Exit(1)
 }
-
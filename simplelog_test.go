@@ -1,7 +1,21 @@
 package minutil
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestSimpleLog(t *testing.T) {
@@ -9,4 +23,328 @@ func TestSimpleLog(t *testing.T) {
 	Error("This is an error message")
 	Warn("This is a warning message")
 	Debug("This is a debug message")
-}
\ No newline at end of file
+}
+func TestLoggerLevelAndFields(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewLogger(WithOutput(&buf), WithLevel(LevelInfo), WithEncoder(&ConsoleEncoder{}))
+	logger.Debug("hidden")
+	logger.With("service", "auth").Info("login", "user", 42, "agent", "Mozilla 5.0")
+
+	out := buf.String()
+	if strings.Contains(out, "hidden") {
+		t.Errorf("Expected debug message to be filtered, got %q", out)
+	}
+	if !strings.Contains(out, `[INFO] login service=auth user=42 agent="Mozilla 5.0"`) {
+		t.Errorf("Unexpected console output: %q", out)
+	}
+	if !strings.Contains(out, "simplelog_test.go:") || strings.Contains(out, "\033[") {
+		t.Errorf("Expected caller without colors, got %q", out)
+	}
+}
+
+func TestLoggerJSON(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewLogger(WithOutput(&buf), WithEncoder(&JSONEncoder{}))
+	logger.With(F("request_id", "r1")).Error("failed", "err", errors.New("boom"), "odd")
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("Expected a JSON line, got %q: %v", buf.String(), err)
+	}
+	if entry["level"] != "ERROR" || entry["msg"] != "failed" || entry["request_id"] != "r1" || entry["err"] != "boom" || entry["!BADKEY"] != "odd" {
+		t.Errorf("Unexpected JSON entry: %v", entry)
+	}
+	if entry["func"] != "TestLoggerJSON" {
+		t.Errorf("Expected caller to be the test function, got %v", entry["func"])
+	}
+}
+
+func TestRotatingFile(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "logs", "app.log")
+	file, err := NewRotatingFile(filename, WithMaxSize(64), WithMaxBackups(2))
+	if err != nil {
+		t.Fatalf("Failed to open log file: %v", err)
+	}
+	logger := NewLogger(WithOutput(file), WithSink(&bytes.Buffer{}, &JSONEncoder{}))
+	for i := 0; i < 10; i++ {
+		logger.Info("rotate", "i", i)
+	}
+	if err := logger.Close(); err != nil {
+		t.Fatalf("Failed to close logger: %v", err)
+	}
+
+	backups, _ := filepath.Glob(filepath.Join(dir, "logs", "app-*.log"))
+	if len(backups) != 2 {
+		t.Errorf("Expected 2 backups to be kept, got %v", backups)
+	}
+	data, err := os.ReadFile(filename)
+	if err != nil || !strings.Contains(string(data), "i=9") {
+		t.Errorf("Expected current file to contain the last entry, got %q %v", data, err)
+	}
+	if strings.Contains(string(data), "\033[") {
+		t.Errorf("Expected no colors in a file output")
+	}
+}
+
+func TestRotatingFileKeepsAllBackups(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "app.log")
+	file, err := NewRotatingFile(filename, WithMaxSize(64))
+	if err != nil {
+		t.Fatalf("Failed to open log file: %v", err)
+	}
+	logger := NewLogger(WithOutput(file))
+	for i := 0; i < 200; i++ {
+		logger.Info("rotate", "i", i)
+	}
+	logger.Close()
+
+	files, _ := filepath.Glob(filepath.Join(dir, "app*.log"))
+	lines := 0
+	for _, name := range files {
+		data, _ := os.ReadFile(name)
+		lines += strings.Count(string(data), "\n")
+	}
+	if lines != 200 {
+		t.Errorf("Expected all 200 lines to be kept across %d files, got %d", len(files), lines)
+	}
+}
+
+func TestAsyncWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewAsyncWriter(&buf, 4)
+	logger := NewLogger(WithOutput(w), WithEncoder(&JSONEncoder{}))
+	for i := 0; i < 100; i++ {
+		logger.Info("async", "i", i)
+	}
+	if err := logger.Sync(); err != nil {
+		t.Fatalf("Failed to sync logger: %v", err)
+	}
+	if n := strings.Count(buf.String(), "\n"); n != 100 {
+		t.Errorf("Expected 100 lines after Sync, got %d", n)
+	}
+	logger.Close()
+	if _, err := w.Write([]byte("x")); err == nil {
+		t.Errorf("Expected write after Close to fail")
+	}
+}
+
+func TestLoggerContext(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewLogger(WithOutput(&buf), WithEncoder(&JSONEncoder{}))
+	ctx := ContextWithTrace(ContextWithRequestID(context.Background(), "r1"), "t1", "s1")
+	ctx = ContextWithLogger(ctx, logger.With("user", "u1"))
+
+	InfoCtx(ctx, "paid %d", 42)
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("Expected a JSON line, got %q: %v", buf.String(), err)
+	}
+	if entry["msg"] != "paid 42" || entry["user"] != "u1" || entry["request_id"] != "r1" || entry["trace_id"] != "t1" || entry["span_id"] != "s1" {
+		t.Errorf("Unexpected JSON entry: %v", entry)
+	}
+	if entry["func"] != "TestLoggerContext" {
+		t.Errorf("Expected caller to be the test function, got %v", entry["func"])
+	}
+}
+
+func TestSlogHandler(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewLogger(WithOutput(&buf), WithLevel(LevelInfo), WithEncoder(&JSONEncoder{}))
+	sl := slog.New(NewSlogHandler(logger)).With("component", "gorm").WithGroup("sql")
+	sl.Debug("hidden")
+	sl.InfoContext(ContextWithRequestID(context.Background(), "r1"), "query", "rows", 3, slog.Group("timing", "ms", 12))
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("Expected a single JSON line, got %q: %v", buf.String(), err)
+	}
+	if entry["msg"] != "query" || entry["component"] != "gorm" || entry["sql.rows"] != float64(3) || entry["sql.timing.ms"] != float64(12) || entry["request_id"] != "r1" {
+		t.Errorf("Unexpected JSON entry: %v", entry)
+	}
+	if entry["func"] != "TestSlogHandler" {
+		t.Errorf("Expected caller to be the test function, got %v", entry["func"])
+	}
+}
+
+func TestSlogLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewSlogLogger(slog.NewJSONHandler(&buf, &slog.HandlerOptions{AddSource: true}))
+	logger.With("user", "u1").Warn("retry", "attempt", 2)
+
+	var record map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("Expected a slog JSON line, got %q: %v", buf.String(), err)
+	}
+	source, _ := record["source"].(map[string]interface{})
+	if record["level"] != "WARN" || record["msg"] != "retry" || record["user"] != "u1" || record["attempt"] != float64(2) {
+		t.Errorf("Unexpected slog record: %v", record)
+	}
+	if fn, _ := source["function"].(string); !strings.HasSuffix(fn, "TestSlogLogger") {
+		t.Errorf("Expected source to be the test function, got %v", source)
+	}
+}
+
+func TestPackageLevels(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewLogger(WithOutput(&buf), WithEncoder(&ConsoleEncoder{}))
+	// 包名取自调用位置所在的目录
+	caller, _ := getCaller(0)
+	if err := logger.SetLevelSpec("warn," + caller.Package + "=debug"); err != nil {
+		t.Fatalf("Failed to set level spec: %v", err)
+	}
+	logger.Debug("visible")
+	logger.SetPackageLevel(caller.Package, LevelError)
+	logger.Warn("hidden")
+	logger.ResetPackageLevel(caller.Package)
+	logger.Warn("global")
+
+	out := buf.String()
+	if !strings.Contains(out, "visible") || strings.Contains(out, "hidden") || !strings.Contains(out, "global") {
+		t.Errorf("Unexpected output: %q", out)
+	}
+	if logger.Level() != LevelWarn || len(logger.PackageLevels()) != 0 {
+		t.Errorf("Unexpected levels: %v %v", logger.Level(), logger.PackageLevels())
+	}
+}
+
+func TestLevelHandler(t *testing.T) {
+	logger := NewLogger(WithOutput(io.Discard), WithLevel(LevelInfo))
+	handler := LevelHandler(logger)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/", strings.NewReader(`{"package":"gorm","level":"debug"}`)))
+	if w.Code != http.StatusOK || logger.LevelFor("gorm") != LevelDebug {
+		t.Fatalf("Expected package level to be set, got %d %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/?level=error", nil))
+	var state struct {
+		Level    string            `json:"level"`
+		Packages map[string]string `json:"packages"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &state); err != nil || state.Level != "ERROR" || state.Packages["gorm"] != "DEBUG" {
+		t.Errorf("Unexpected state: %s %v", w.Body.String(), err)
+	}
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/?level=verbose", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected unknown level to be rejected, got %d", w.Code)
+	}
+}
+
+func TestToggleDebug(t *testing.T) {
+	logger := NewLogger(WithOutput(io.Discard), WithLevel(LevelInfo))
+	logger.SetPackageLevel("gorm", LevelWarn)
+
+	saved := toggleDebug(logger, []string{"gorm", "middleware"}, nil)
+	if logger.LevelFor("gorm") != LevelDebug || logger.LevelFor("middleware") != LevelDebug || logger.Level() != LevelInfo {
+		t.Errorf("Expected packages to be switched to DEBUG, got %v", logger.PackageLevels())
+	}
+	if saved = toggleDebug(logger, []string{"gorm", "middleware"}, saved); saved != nil {
+		t.Errorf("Expected saved state to be cleared")
+	}
+	if levels := logger.PackageLevels(); len(levels) != 1 || levels["gorm"] != LevelWarn {
+		t.Errorf("Expected package levels to be restored, got %v", levels)
+	}
+}
+
+func TestLogSampling(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewLogger(WithOutput(&buf), WithEncoder(&ConsoleEncoder{}),
+		WithSampling(LevelError, SamplingPolicy{Interval: time.Hour, First: 3, Thereafter: 1000}))
+	for i := 0; i < 10000; i++ {
+		logger.Errorf("downstream failed: attempt %d", i)
+	}
+	logger.Info("not sampled")
+	logger.Sync()
+
+	out := buf.String()
+	if n := strings.Count(out, "downstream failed"); n != 13 {
+		t.Errorf("Expected 12 sampled lines and 1 summary, got %d:\n%s", n, out)
+	}
+	if !strings.Contains(out, `suppressed 9,988 similar messages sampled_message="downstream failed: attempt 9999"`) {
+		t.Errorf("Expected a summary line, got:\n%s", out)
+	}
+	if !strings.Contains(out, "not sampled") {
+		t.Errorf("Expected other levels not to be sampled")
+	}
+}
+
+func TestLogSamplingInterval(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewLogger(WithOutput(&buf), WithEncoder(&ConsoleEncoder{}),
+		WithSampling(LevelWarn, SamplingPolicy{Interval: 20 * time.Millisecond, First: 1, By: SampleByMessage}))
+	for i := 0; i < 5; i++ {
+		logger.Warn("cache miss")
+	}
+	time.Sleep(30 * time.Millisecond)
+	logger.Warn("cache miss")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 || !strings.Contains(lines[1], "suppressed 4 similar messages") || !strings.HasSuffix(lines[2], "cache miss") {
+		t.Errorf("Expected first line, summary and first line of the next interval, got:\n%s", buf.String())
+	}
+}
+
+// lockedBuffer 是并发安全的 bytes.Buffer，采样器的定时器会在其他协程中写入
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestLogSamplingSummaryAfterFlood(t *testing.T) {
+	var buf lockedBuffer
+	logger := NewLogger(WithOutput(&buf), WithEncoder(&ConsoleEncoder{}),
+		WithSampling(LevelError, SamplingPolicy{Interval: 20 * time.Millisecond, First: 1}))
+	defer logger.Close()
+	for i := 0; i < 10; i++ {
+		logger.Error("downstream failed")
+	}
+
+	// 日志停止后，不需要新的日志或 Sync，周期结束时也会输出汇总
+	deadline := time.Now().Add(time.Second)
+	for !strings.Contains(buf.String(), "suppressed 9 similar messages") {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected a summary after the interval, got:\n%s", buf.String())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestLogSamplingMaxGroups(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewLogger(WithOutput(&buf), WithEncoder(&ConsoleEncoder{}),
+		WithSampling(LevelError, SamplingPolicy{Interval: time.Hour, By: SampleByMessage}))
+	for i := 0; i < maxSampleGroups+100; i++ {
+		logger.Error("user " + strconv.Itoa(i) + " not found")
+	}
+
+	// 组数达到上限时最早的组提前输出汇总并被删除
+	s := logger.core.samplers[LevelError]
+	if n := len(s.groups); n != maxSampleGroups {
+		t.Errorf("Expected %d sample groups, got %d", maxSampleGroups, n)
+	}
+	if n := strings.Count(buf.String(), "suppressed 1 similar messages"); n != 100 {
+		t.Errorf("Expected 100 early summaries, got %d", n)
+	}
+	logger.Sync()
+	if n := strings.Count(buf.String(), "suppressed 1 similar messages"); n != maxSampleGroups+100 {
+		t.Errorf("Expected every suppressed message to be summarized, got %d", n)
+	}
+}
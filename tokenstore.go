@@ -1,7 +1,43 @@
 package minutil
 
-// TokenStore 是 Token 存储的接口
+import (
+	"errors"
+	"time"
+)
+
+// ErrRefreshTokenNotFound 表示刷新 Token 不存在或已过期
+var ErrRefreshTokenNotFound = errors.New("refresh token not found")
+
+// TokenStore 是 Token 存储的接口，所有记录都以 jti 等标识为键，不保存 Token 原文
 type TokenStore interface {
-	Get(token string) (bool, error)
-	Set(token string, invalid bool) error
-}
\ No newline at end of file
+	// Get 获取 Token 的失效状态，id 为 Token 的 jti（旧 Token 为其 SHA-256 摘要）
+	Get(id string) (bool, error)
+	// Set 设置 Token 的失效状态，expiration 之后记录自动删除，为 0 时永久保存
+	Set(id string, invalid bool, expiration time.Duration) error
+
+	// SaveRefreshToken 记录一个新签发、尚未使用的刷新 Token
+	SaveRefreshToken(id string, expiration time.Duration) error
+	// UseRefreshToken 原子地把刷新 Token 标记为已使用，返回它在此之前是否已被使用过
+	UseRefreshToken(id string) (bool, error)
+	// RevokeFamily 撤销整条刷新链
+	RevokeFamily(family string, expiration time.Duration) error
+	// IsFamilyRevoked 检查刷新链是否已被撤销
+	IsFamilyRevoked(family string) (bool, error)
+
+	// SetUserNotBefore 设置用户的失效水位线，在此之前签发的 Token 全部失效。
+	// 每个用户只保存一条记录，不会过期
+	SetUserNotBefore(userID string, notBefore time.Time) error
+	// GetUserNotBefore 返回用户的失效水位线，没有设置时返回零值
+	GetUserNotBefore(userID string) (time.Time, error)
+
+	// SaveSession 创建或覆盖会话，expiration 之后会话自动删除，小于等于 0 时直接删除会话
+	SaveSession(session *Session, expiration time.Duration) error
+	// GetSession 返回会话，不存在时返回 nil
+	GetSession(id string) (*Session, error)
+	// ListSessions 返回用户的全部有效会话
+	ListSessions(userID string) ([]*Session, error)
+	// TouchSession 更新会话的最近访问时间，会话不存在时什么也不做
+	TouchSession(id string, lastSeen time.Time) error
+	// DeleteSession 删除会话
+	DeleteSession(id string) error
+}